@@ -0,0 +1,154 @@
+package pico
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// budget scopes
+const (
+	BudgetTask = "task"
+	BudgetFile = "file"
+	BudgetDisk = "disk"
+)
+
+// BudgetError means the output exceeds, or is estimated to exceed, a byte
+// budget or the free space of the output filesystem
+type BudgetError struct {
+	scope     string
+	file      string
+	limit     int64
+	bytes     int64
+	estimated bool
+}
+
+func newBudgetError(scope, file string, limit, bytes int64, estimated bool) *BudgetError {
+	return &BudgetError{scope: scope, file: file, limit: limit, bytes: bytes, estimated: estimated}
+}
+
+func (e *BudgetError) Error() string {
+	limit := fmt.Sprintf("the %s budget (%d bytes)", e.scope, e.limit)
+	if e.scope == BudgetDisk {
+		limit = fmt.Sprintf("the free space of the output filesystem (%d bytes)", e.limit)
+	}
+
+	if e.estimated {
+		return fmt.Sprintf("estimated output of %s (%d bytes) exceeds %s", e.file, e.bytes, limit)
+	}
+	return fmt.Sprintf("output of %s (%d bytes written) exceeds %s", e.file, e.bytes, limit)
+}
+
+// Scope returns which budget is exceeded, one of BudgetTask, BudgetFile and
+// BudgetDisk
+func (e *BudgetError) Scope() string {
+	return e.scope
+}
+
+// File returns the file being converted when the budget is exceeded
+func (e *BudgetError) File() string {
+	return e.file
+}
+
+// Limit returns the budget in bytes
+func (e *BudgetError) Limit() int64 {
+	return e.limit
+}
+
+// Bytes returns the bytes written, or estimated to be written
+func (e *BudgetError) Bytes() int64 {
+	return e.bytes
+}
+
+// Estimated reports whether the budget is exceeded by the estimate before
+// the conversion starts
+func (e *BudgetError) Estimated() bool {
+	return e.estimated
+}
+
+// WithByteBudget limits the bytes written by the task. A file whose estimated
+// output does not fit in the remaining budget is skipped, and the task is
+// aborted once the written bytes exceed the budget.
+func WithByteBudget(bytes int64) CallOption {
+	return func(p *Parameters, command []string) []string {
+		p.byteBudget = bytes
+		return command
+	}
+}
+
+// WithFileByteBudget limits the bytes written for every file. A file whose
+// estimated output exceeds the budget is skipped, and the conversion of a
+// file is stopped once its written bytes exceed the budget.
+func WithFileByteBudget(bytes int64) CallOption {
+	return func(p *Parameters, command []string) []string {
+		p.fileByteBudget = bytes
+		return command
+	}
+}
+
+// WithFreeSpaceCheck skips a file whose estimated output does not fit in the
+// free space of the output filesystem, it is implied by the byte budgets.
+func WithFreeSpaceCheck() CallOption {
+	return func(p *Parameters, command []string) []string {
+		p.freeSpaceCheck = true
+		return command
+	}
+}
+
+func (p *Parameters) budgeted() bool {
+	return p.byteBudget > 0 || p.fileByteBudget > 0 || p.freeSpaceCheck
+}
+
+// checkBudget estimates the output of the pdf from its page geometry, given
+// by the pdfinfo entries `infos`, and the output format, the estimate is
+// checked against the budgets and the free space. `written` is the bytes the
+// task has written so far.
+func (p *Parameters) checkBudget(pdf string, pages int32, infos map[string]string, written int64) error {
+	if !p.budgeted() {
+		return nil
+	}
+
+	_, _, estimate := p.estimate(infos, pages)
+
+	p.logger.Log(LevelDebug, "output estimated", Field{"file", pdf}, Field{"bytes", estimate})
+
+	if p.fileByteBudget > 0 && estimate > p.fileByteBudget {
+		return errors.WithStack(newBudgetError(BudgetFile, pdf, p.fileByteBudget, estimate, true))
+	}
+	if p.byteBudget > 0 && written+estimate > p.byteBudget {
+		return errors.WithStack(newBudgetError(BudgetTask, pdf, p.byteBudget-written, estimate, true))
+	}
+
+	dir := filepath.Dir(p.outputPath(pdf, -1, 1, pages))
+	if free, ok := freeSpace(dir); ok && estimate > free {
+		return errors.WithStack(newBudgetError(BudgetDisk, pdf, free, estimate, true))
+	}
+
+	return nil
+}
+
+// freeSpace returns the bytes available to unprivileged users on the
+// filesystem of `dir`, or the closest existing parent of `dir`
+func freeSpace(dir string) (int64, bool) {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return 0, false
+		}
+		dir = parent
+	}
+	return statfs(dir)
+}
+
+// fileSize returns the size of the file, or 0 if it could not be stat
+func fileSize(file string) int64 {
+	if info, err := os.Stat(file); err == nil {
+		return info.Size()
+	}
+	return 0
+}
@@ -0,0 +1,165 @@
+package pico
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileByteBudget(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+
+	// every page of the fake pdftoppm is 2 bytes
+	var files []string
+	for i := 0; i < 3; i++ {
+		files = append(files, fakePDF(t, dir, fmt.Sprintf("%d.pdf", i), 8, 0.05))
+	}
+
+	task, err := ConvertFiles(files,
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+		WithFileByteBudget(5),
+		WithJob(3),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+	task.Wait()
+
+	assert.Equal(t, StateFailed, task.State())
+	assert.Len(t, task.Errors(), 3)
+	for _, err := range task.Errors() {
+		var budgetError *BudgetError
+		if assert.ErrorAs(t, err, &budgetError) {
+			assert.Equal(t, BudgetFile, budgetError.Scope())
+			assert.False(t, budgetError.Estimated())
+			assert.EqualValues(t, 6, budgetError.Bytes())
+		}
+	}
+
+	report := task.Report()
+	for _, pdf := range files {
+		assert.NotEmpty(t, report.Files[pdf].Skipped, "conversion should stop once the budget is exceeded")
+	}
+}
+
+func TestTaskByteBudget(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+
+	var files []string
+	for i := 0; i < 3; i++ {
+		files = append(files, fakePDF(t, dir, fmt.Sprintf("%d.pdf", i), 5, 0.05))
+	}
+
+	task, err := ConvertFiles(files,
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+		WithByteBudget(7),
+		WithJob(1),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+	task.Wait()
+
+	var budgetError *BudgetError
+	assert.ErrorAs(t, task.Error(), &budgetError)
+	assert.Equal(t, BudgetTask, budgetError.Scope())
+	assert.Equal(t, StateFailed, task.State())
+	assert.EqualValues(t, 8, task.BytesWritten())
+	assert.EqualValues(t, 8, task.Status().BytesWritten)
+	assert.EqualValues(t, 4, task.Pages.Finished())
+}
+
+func TestEstimatedByteBudget(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+
+	large := fakePDF(t, dir, "large.pdf", 10, 0, "Page size: 612 x 792 pts (letter)")
+	small := fakePDF(t, dir, "small.pdf", 1, 0, "Page size: 72 x 72 pts")
+
+	// 10 letter pages at 200 dpi in ppm are about 58 MB
+	_, err := Convert(large,
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+		WithByteBudget(50<<20),
+	)
+	var budgetError *BudgetError
+	if assert.ErrorAs(t, err, &budgetError) {
+		assert.True(t, budgetError.Estimated())
+		assert.Equal(t, large, budgetError.File())
+	}
+
+	task, err := ConvertFiles([]string{large, small},
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+		WithFileByteBudget(1<<20),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+	task.Wait()
+
+	report := task.Report()
+	assert.ErrorAs(t, report.Files[large].Errors[-1][0], &budgetError)
+	assert.Empty(t, report.Files[large].Succeeded)
+	assert.False(t, report.Files[small].Failed())
+}
+
+func TestFreeSpace(t *testing.T) {
+	free, ok := freeSpace(fmt.Sprintf("%s/not/yet/created", t.TempDir()))
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+		assert.True(t, ok)
+		assert.True(t, free > 0)
+	}
+
+	err := errors.WithStack(newBudgetError(BudgetDisk, "a.pdf", 10, 20, true))
+	assert.Contains(t, err.Error(), "free space")
+}
+
+func TestBudgetReusesPdfinfo(t *testing.T) {
+	cases := []struct {
+		name    string
+		options []CallOption
+	}{
+		{"convert files", nil},
+		{"preflight", []CallOption{WithPreflight()}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			poppler := fakePoppler(t)
+			calls := countPdfinfo(t, poppler)
+			dir := t.TempDir()
+			files := []string{fakePDF(t, dir, "a.pdf", 2, 0), fakePDF(t, dir, "b.pdf", 3, 0)}
+
+			task, err := ConvertFiles(files, append(c.options,
+				WithPopplerPath(poppler),
+				WithOutputFolder(t.TempDir()),
+				WithFileByteBudget(1<<30),
+			)...)
+			assert.NoError(t, err, "conversion task initialization should not failed")
+			task.Wait()
+
+			assert.NoError(t, task.Error())
+			assert.Equal(t, len(files), calls())
+		})
+	}
+}
+
+// countPdfinfo wraps the fake pdfinfo to count the files it is run for
+func countPdfinfo(t *testing.T, poppler string) func() int {
+	pdfinfo := filepath.Join(poppler, "pdfinfo")
+	log := filepath.Join(t.TempDir(), "calls")
+	assert.NoError(t, os.Rename(pdfinfo, pdfinfo+".real"))
+	script := fmt.Sprintf("#!/bin/sh\n[ \"$1\" = \"-v\" ] || echo \"$1\" >> %q\nexec %q \"$@\"\n", log, pdfinfo+".real")
+	assert.NoError(t, ioutil.WriteFile(pdfinfo, []byte(script), 0755))
+
+	return func() int {
+		data, _ := ioutil.ReadFile(log)
+		return strings.Count(string(data), "\n")
+	}
+}
@@ -27,21 +27,123 @@ func Marquee(textGetter func() string, ws uint, wcc ...decor.WC) decor.Decorator
 	return decor.Any(f, wcc...)
 }
 
-func Bar(task interface{}) *mpb.Progress {
+// Throughput displays pages per second and the estimated remaining time
+func Throughput(o pico.Observable, wcc ...decor.WC) decor.Decorator {
+	f := func(s decor.Statistics) string {
+		stats := o.Stats()
+		if stats.Throughput <= 0 {
+			return ""
+		}
+		return fmt.Sprintf("%.1f p/s ETA %s", stats.Throughput, stats.ETA.Round(time.Second))
+	}
+	return decor.Any(f, wcc...)
+}
+
+// Bar displays the progress of the task, the updater must be registered
+// before the task starts
+func Bar(task interface{}, u *updater) *mpb.Progress {
 	switch t := task.(type) {
 	case *pico.SingleTask:
-		return singleTaskBar(t)
+		return singleTaskBar(t, u)
 	case *pico.BatchTask:
-		return batchTaskBar(t)
+		return batchTaskBar(t, u)
 	default:
 		panic("unknown task type")
 	}
 }
 
-var _txtDone = "\x1b[32mDone!\x1b[0m"
-var _txtAbort = "\x1b[31mAborted\x1b[0m"
+// colored wraps the text in the ANSI colour code unless colours are disabled
+func colored(code, text string) string {
+	if noColor {
+		return text
+	}
+	return fmt.Sprintf("\x1b[%sm%s\x1b[0m", code, text)
+}
+
+func txtDone() string {
+	return colored("32", "Done!")
+}
+
+func txtAbort() string {
+	return colored("31", "Aborted")
+}
+
+// updater refreshes the bars from their observables whenever the task
+// publishes an event, the bars are completed when the task is done. It is
+// registered as hooks before the task starts so the end of the task is never
+// missed.
+type updater struct {
+	bars        []*mpb.Bar
+	observables []pico.Observable
+	finished    []bool
+
+	wake chan struct{}
+	done chan struct{}
+}
+
+func newUpdater() *updater {
+	return &updater{wake: make(chan struct{}, 1), done: make(chan struct{})}
+}
+
+// options registers the hooks that wake up the updater
+func (u *updater) options() []pico.CallOption {
+	return []pico.CallOption{
+		pico.WithOnFileStart(func(pico.FileEvent) { u.notify() }),
+		pico.WithOnPageDone(func(pico.PageEvent) { u.notify() }),
+		pico.WithOnFileDone(func(pico.FileEvent) { u.notify() }),
+		pico.WithOnError(func(*pico.ConversionError) { u.notify() }),
+		pico.WithOnTaskDone(func(pico.TaskEvent) { close(u.done) }),
+	}
+}
+
+// notify wakes up the updater, only the latest event matters
+func (u *updater) notify() {
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (u *updater) add(bar *mpb.Bar, o pico.Observable) {
+	u.bars = append(u.bars, bar)
+	u.observables = append(u.observables, o)
+	u.finished = append(u.finished, false)
+}
+
+func (u *updater) run() {
+	for {
+		select {
+		case <-u.wake:
+			u.update(false)
+		case <-u.done:
+			u.update(true)
+			return
+		}
+	}
+}
+
+func (u *updater) update(done bool) {
+	for i, bar := range u.bars {
+		if u.finished[i] {
+			continue
+		}
+
+		o := u.observables[i]
+		switch {
+		case o.Aborted():
+			bar.Abort(false)
+			u.finished[i] = true
+		case o.Completed() || done:
+			bar.SetTotal(int64(o.Total()), true)
+			u.finished[i] = true
+		default:
+			bar.SetTotal(int64(o.Total()), false)
+			bar.SetCurrent(int64(o.Finished()))
+		}
+	}
+}
 
-func singleTaskBar(t *pico.SingleTask) *mpb.Progress {
+func singleTaskBar(t *pico.SingleTask, u *updater) *mpb.Progress {
 	p := mpb.New()
 
 	for id, convertor := range t.Convertors {
@@ -51,8 +153,8 @@ func singleTaskBar(t *pico.SingleTask) *mpb.Progress {
 		status := Marquee(func() string {
 			return c.Filename()
 		}, 30)
-		status = decor.OnComplete(status, _txtDone)
-		status = decor.OnAbort(status, _txtAbort)
+		status = decor.OnComplete(status, txtDone())
+		status = decor.OnAbort(status, txtAbort())
 
 		bar := p.AddBar(0,
 			mpb.PrependDecorators(
@@ -60,16 +162,20 @@ func singleTaskBar(t *pico.SingleTask) *mpb.Progress {
 				status,
 				decor.CountersNoUnit("%d / %d", decor.WCSyncWidth),
 			),
-			mpb.AppendDecorators(decor.Percentage(decor.WC{W: 5})),
+			mpb.AppendDecorators(
+				decor.Percentage(decor.WC{W: 5}),
+				Throughput(convertor, decor.WC{W: 20}),
+			),
 		)
 
-		go complete(bar, convertor)
+		u.add(bar, convertor)
 	}
 
+	go u.run()
 	return p
 }
 
-func batchTaskBar(t *pico.BatchTask) *mpb.Progress {
+func batchTaskBar(t *pico.BatchTask, u *updater) *mpb.Progress {
 	p := mpb.New()
 
 	// total file count
@@ -81,7 +187,21 @@ func batchTaskBar(t *pico.BatchTask) *mpb.Progress {
 		),
 		mpb.AppendDecorators(decor.Percentage(decor.WC{W: 5})),
 	)
-	go complete(bar, t)
+	u.add(bar, t)
+
+	// total page count, it grows as files start unless preflight is enabled
+	name = "Total page"
+	bar = p.AddBar(0,
+		mpb.PrependDecorators(
+			decor.Name(name, decor.WC{W: len(name) + 1, C: decor.DidentRight}),
+			decor.CountersNoUnit("%d / %d", decor.WCSyncWidth),
+		),
+		mpb.AppendDecorators(
+			decor.Percentage(decor.WC{W: 5}),
+			Throughput(t.Pages, decor.WC{W: 20}),
+		),
+	)
+	u.add(bar, t.Pages)
 
 	for id, convertor := range t.Convertors {
 		worker := fmt.Sprintf("Worker#%02d:", id)
@@ -90,8 +210,8 @@ func batchTaskBar(t *pico.BatchTask) *mpb.Progress {
 		status := Marquee(func() string {
 			return c.Filename()
 		}, 30)
-		status = decor.OnComplete(status, _txtDone)
-		status = decor.OnAbort(status, _txtAbort)
+		status = decor.OnComplete(status, txtDone())
+		status = decor.OnAbort(status, txtAbort())
 
 		bar := p.AddBar(int64(convertor.Total()),
 			mpb.PrependDecorators(
@@ -99,26 +219,15 @@ func batchTaskBar(t *pico.BatchTask) *mpb.Progress {
 				status,
 				decor.CountersNoUnit("%d / %d", decor.WCSyncWidth),
 			),
-			mpb.AppendDecorators(decor.Percentage(decor.WC{W: 5})),
+			mpb.AppendDecorators(
+				decor.Percentage(decor.WC{W: 5}),
+				Throughput(convertor, decor.WC{W: 20}),
+			),
 		)
 
-		go complete(bar, convertor)
+		u.add(bar, convertor)
 	}
 
+	go u.run()
 	return p
 }
-
-func complete(bar *mpb.Bar, o pico.Observable) {
-	for !bar.Completed() {
-		time.Sleep(time.Duration(500))
-		switch {
-		case o.Aborted():
-			bar.Abort(false)
-		case o.Completed():
-			bar.SetTotal(int64(o.Total()), true)
-		default:
-			bar.SetTotal(int64(o.Total()), false)
-			bar.SetCurrent(int64(o.Finished()))
-		}
-	}
-}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DeathKing/pico"
+)
+
+// usage:
+// pdf2image convert [options] path-to-pdf-file...
+//    `-` reads the file list from stdin, `@list.txt` reads it from list.txt
+// -j | --job
+// -wid | --worker-id
+//     append worker id when output file
+// -d | --dpi
+// -f | --first-page
+// -l | --last-page
+// -fmt | --format
+// -upw | --user-password
+// -opw | --owner-password
+// -t | --timeout
+// -q | --quality
+// -opt | --optimize
+// --progressive
+//    jpeg options
+// --scale-to, --scale-to-x, --scale-to-y
+// --cropbox
+// --hide-annotations
+// --single-file
+// --strict
+// --cairo
+//    use pdftocairo instead of pdftoppm
+// --poppler-path
+// --ordered
+// --preflight
+// --byte-budget, --file-byte-budget, --free-space-check
+// --manifest
+//    convert the files listed in a CSV or JSON manifest with their own dpi,
+//    page range, format, passwords and output folder
+// -0 | --null
+//    file lists are separated by NUL rather than newline, like find -print0
+// -r | --recursive
+//    walk folders recursively, pdf files are detected by content
+// --include, --exclude
+//    glob patterns of the files to convert or skip in folders, repeatable
+// -o | --output-folder
+//    set output folder name
+// --silent
+//    do not display any infomation
+// --entry
+//    print every converted page
+// --bar (default)
+//    show conversion progress bar
+// --quiet
+//    do not display the progress, errors are printed without stack traces
+// --no-color
+//    do not use colors, also disabled by the NO_COLOR environment variable
+// --output
+//    text (default) or jsonl, jsonl prints one JSON object per event
+// -gray | --grayscale
+// -trans | --transparent
+// -v | --verbose
+//
+// The first SIGINT or SIGTERM stops the conversion, removes the partial
+// outputs and exits with 128 plus the signal number, the second one exits
+// at once.
+
+var (
+	dpi          int
+	worker       int
+	firstPage    int
+	lastPage     int
+	outputFolder string
+	outputFormat string
+
+	document *documentFlags
+	timeout  time.Duration
+
+	jpegQuality     int
+	jpegOptimize    bool
+	jpegProgressive bool
+
+	scaleTo  int
+	scaleToX int
+	scaleToY int
+
+	useCropBox      bool
+	hideAnnotations bool
+	singleFile      bool
+	strict          bool
+	usePdftocairo   bool
+	grayscale       bool
+	transparent     bool
+	verbose         bool
+
+	ordered   int
+	preflight bool
+
+	manifest  string
+	null      bool
+	recursive bool
+	include   patterns
+	exclude   patterns
+
+	byteBudget     int64
+	fileByteBudget int64
+	freeSpaceCheck bool
+
+	silent  bool
+	quiet   bool
+	noColor bool
+	entry   bool
+	showBar bool
+	output  string
+	events  *jsonlWriter
+	plain   *plainProgress
+	bars    *updater
+	signals *interrupter
+
+	// started is when the conversion starts, older outputs are never removed
+	started time.Time
+
+	appendWorkerId bool
+
+	nameFn = func(pdf string, index, first, last int32) string {
+		wid := ""
+		if appendWorkerId {
+			wid = strconv.Itoa(int(index))
+		}
+
+		fileName := filepath.Base(pdf)
+		return fmt.Sprintf("%s-%s", fileName[:len(fileName)-len(filepath.Ext(fileName))], wid)
+	}
+)
+
+func runConvert(args []string) int {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	flag := newFlagSet("convert", "[options] file|folder|-|@list.txt...",
+		"Convert pdf files to images, - reads the file list from stdin and @list.txt from list.txt")
+	document = newDocumentFlags(flag)
+
+	usage := "output dpi"
+	flag.IntVar(&dpi, "d", 72, usage)
+	flag.IntVar(&dpi, "dpi", 72, usage)
+
+	usage = "worker count"
+	flag.IntVar(&worker, "j", -1, usage)
+	flag.IntVar(&worker, "job", -1, usage)
+
+	usage = "append worker id in filename"
+	flag.BoolVar(&appendWorkerId, "wid", false, usage)
+	flag.BoolVar(&appendWorkerId, "worker-id", false, usage)
+
+	usage = "fisrt page"
+	flag.IntVar(&firstPage, "f", -1, usage)
+	flag.IntVar(&firstPage, "first-page", -1, usage)
+
+	usage = "last page"
+	flag.IntVar(&lastPage, "l", -1, usage)
+	flag.IntVar(&lastPage, "last-page", -1, usage)
+
+	usage = "output folder"
+	flag.StringVar(&outputFolder, "o", ".", usage)
+	flag.StringVar(&outputFolder, "output-folder", ".", usage)
+
+	usage = "output format (jpeg, png, tiff, ppm)"
+	flag.StringVar(&outputFormat, "fmt", "jpeg", usage)
+	flag.StringVar(&outputFormat, "format", "jpeg", usage)
+
+	usage = "timeout of the whole conversion, e.g. 30s (default no timeout)"
+	flag.DurationVar(&timeout, "t", 0, usage)
+	flag.DurationVar(&timeout, "timeout", 0, usage)
+
+	usage = "jpeg quality from 0 to 100 (default poppler's)"
+	flag.IntVar(&jpegQuality, "q", -1, usage)
+	flag.IntVar(&jpegQuality, "quality", -1, usage)
+
+	usage = "optimize jpeg"
+	flag.BoolVar(&jpegOptimize, "opt", false, usage)
+	flag.BoolVar(&jpegOptimize, "optimize", false, usage)
+
+	flag.BoolVar(&jpegProgressive, "progressive", false, "write progressive jpeg")
+
+	flag.IntVar(&scaleTo, "scale-to", 0, "fit the longest side of the image to this size")
+	flag.IntVar(&scaleToX, "scale-to-x", 0, "scale the image width to this size")
+	flag.IntVar(&scaleToY, "scale-to-y", 0, "scale the image height to this size")
+
+	flag.BoolVar(&useCropBox, "cropbox", false, "use the crop box rather than media box")
+	flag.BoolVar(&hideAnnotations, "hide-annotations", false, "do not show annotations")
+	flag.BoolVar(&singleFile, "single-file", false, "convert the first page only")
+	flag.BoolVar(&strict, "strict", false, "stop converting a file on syntax errors")
+	flag.BoolVar(&usePdftocairo, "cairo", false, "use pdftocairo instead of pdftoppm")
+
+	usage = "convert to grayscale"
+	flag.BoolVar(&grayscale, "gray", false, usage)
+	flag.BoolVar(&grayscale, "grayscale", false, usage)
+
+	usage = "keep the background transparent (png and tiff)"
+	flag.BoolVar(&transparent, "trans", false, usage)
+	flag.BoolVar(&transparent, "transparent", false, usage)
+
+	usage = "print debugging information"
+	flag.BoolVar(&verbose, "v", false, usage)
+	flag.BoolVar(&verbose, "verbose", false, usage)
+
+	flag.IntVar(&ordered, "ordered", 0, "deliver pages in order with this buffer size per worker")
+	flag.BoolVar(&preflight, "preflight", false, "count the pages of all the files before converting")
+
+	flag.StringVar(&manifest, "manifest", "",
+		"convert the files of a CSV or JSON manifest, columns: file, dpi, first_page, last_page, format, user_password, owner_password, output_folder")
+
+	usage = "file lists given by - (stdin) or @list.txt are NUL separated"
+	flag.BoolVar(&null, "0", false, usage)
+	flag.BoolVar(&null, "null", false, usage)
+
+	usage = "walk folders recursively"
+	flag.BoolVar(&recursive, "r", false, usage)
+	flag.BoolVar(&recursive, "recursive", false, usage)
+
+	flag.Var(&include, "include", "convert only the files matching the glob pattern in folders, repeatable")
+	flag.Var(&exclude, "exclude", "skip the files and folders matching the glob pattern, repeatable")
+
+	flag.Int64Var(&byteBudget, "byte-budget", 0, "max bytes written by the conversion")
+	flag.Int64Var(&fileByteBudget, "file-byte-budget", 0, "max bytes written for every file")
+	flag.BoolVar(&freeSpaceCheck, "free-space-check", false, "skip files that do not fit in the free space")
+
+	flag.BoolVar(&silent, "silent", false, "do not display any infomation")
+	flag.BoolVar(&quiet, "quiet", false, "do not display the progress, print errors without stack traces")
+	flag.BoolVar(&noColor, "no-color", os.Getenv("NO_COLOR") != "", "do not use colors")
+	flag.BoolVar(&entry, "entry", false, "print every converted page")
+	flag.BoolVar(&showBar, "bar", true, "show conversion progress bar, plain lines are printed if the output is not a terminal")
+	flag.StringVar(&output, "output", "text", "output mode, text or jsonl (one JSON object per page, file, error and a summary)")
+
+	if code, ok := parse(flag, args, 0); !ok {
+		return code
+	}
+	switch {
+	case manifest != "" && flag.NArg() > 0:
+		return report(usageError("file arguments could not be used with --manifest"))
+	case manifest == "" && flag.NArg() == 0:
+		fmt.Fprintf(flag.Output(), "%s: missing file argument\n", flag.Name())
+		flag.Usage()
+		return exitUsage
+	}
+
+	options, err := buildOptions(ctx)
+	if err != nil {
+		return report(err)
+	}
+
+	signals = handleSignals(cancel)
+	defer signals.stop()
+	started = time.Now()
+
+	if manifest != "" {
+		items, err := loadManifest(manifest)
+		if err != nil {
+			return reportConvert(err)
+		}
+
+		task, err := pico.ConvertFiles(pico.FromItems(items), options...)
+		if err != nil {
+			return reportConvert(err)
+		}
+		return run(&task.Task, task)
+	}
+
+	if flag.NArg() == 1 && !isList(flag.Arg(0)) {
+		pdf := flag.Arg(0)
+
+		info, err := os.Stat(pdf)
+		if err != nil {
+			return reportConvert(err)
+		}
+
+		if !info.IsDir() {
+			task, err := pico.Convert(pdf, options...)
+			if err != nil {
+				return reportConvert(err)
+			}
+			return run(&task.Task, task)
+		}
+	}
+
+	walkOptions := []pico.WalkOption{pico.WalkInclude(include...), pico.WalkExclude(exclude...)}
+	if recursive {
+		walkOptions = append(walkOptions, pico.WalkRecursive())
+	}
+	delim := byte('\n')
+	if null {
+		delim = 0
+	}
+	provider := newSource(flag.Args(), delim, walkOptions...)
+
+	task, err := pico.ConvertFiles(provider, options...)
+	if err != nil {
+		return reportConvert(err)
+	}
+
+	return run(&task.Task, task)
+}
+
+// patterns collects the values of a repeatable flag
+type patterns []string
+
+func (p *patterns) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patterns) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
+// buildOptions turns the flags into call options, conflicting or invalid
+// flags are reported
+func buildOptions(ctx context.Context) ([]pico.CallOption, error) {
+	switch {
+	case silent && entry:
+		return nil, usageError("--silent and --entry could not be used together")
+	case quiet && entry:
+		return nil, usageError("--quiet and --entry could not be used together")
+	case scaleTo > 0 && (scaleToX > 0 || scaleToY > 0):
+		return nil, usageError("--scale-to could not be used with --scale-to-x or --scale-to-y")
+	case jpegQuality < -1 || jpegQuality > 100:
+		return nil, usageError("--quality must be in [0, 100]")
+	case timeout < 0:
+		return nil, usageError("--timeout must not be negative")
+	case output != "text" && output != "jsonl":
+		return nil, usageError("--output must be text or jsonl")
+	case output == "jsonl" && entry:
+		return nil, usageError("--output=jsonl and --entry could not be used together")
+	}
+
+	options := []pico.CallOption{
+		pico.WithDpi(dpi),
+		pico.WithFormat(outputFormat),
+		pico.WithContext(ctx),
+		pico.WithOutputFileFn(nameFn),
+		pico.WithFirstPage(firstPage),
+		pico.WithLastPage(lastPage),
+		pico.WithOutputFolder(outputFolder),
+		pico.WithScaleTo(scaleTo),
+		pico.WithScaleToX(scaleToX),
+		pico.WithScaleToY(scaleToY),
+		pico.WithByteBudget(byteBudget),
+		pico.WithFileByteBudget(fileByteBudget),
+	}
+
+	if worker > 0 {
+		options = append(options, pico.WithJob(worker))
+	}
+	if timeout > 0 {
+		options = append(options, pico.WithTimeout(timeout))
+	}
+	if jpegQuality >= 0 {
+		options = append(options, pico.WithJPEGQuality(jpegQuality))
+	}
+	if jpegOptimize {
+		options = append(options, pico.WithJPEGOptimize(true))
+	}
+	if jpegProgressive {
+		options = append(options, pico.WithJPEGProgressive(true))
+	}
+	if useCropBox {
+		options = append(options, pico.WithUseCropBox())
+	}
+	if hideAnnotations {
+		options = append(options, pico.WithHideAnnotations())
+	}
+	if singleFile {
+		options = append(options, pico.WithSingleFile())
+	}
+	if strict {
+		options = append(options, pico.WithStrict())
+	}
+	if usePdftocairo {
+		options = append(options, pico.WithUsePdftocario())
+	}
+	if grayscale {
+		options = append(options, pico.WithGrayScale())
+	}
+	if transparent {
+		options = append(options, pico.WithTransparent())
+	}
+	if verbose {
+		options = append(options, pico.WithVerbose())
+	}
+	if ordered > 0 {
+		options = append(options, pico.WithOrderedEntries(ordered))
+	}
+	if preflight {
+		options = append(options, pico.WithPreflight())
+	}
+	if freeSpaceCheck {
+		options = append(options, pico.WithFreeSpaceCheck())
+	}
+
+	if output == "jsonl" {
+		events = newJSONLWriter(os.Stdout)
+		options = append(options, events.options()...)
+	} else if showBar && !silent && !quiet && !entry {
+		// the progress is registered before the task starts so that no
+		// event is missed
+		if isTerminal(os.Stdout) {
+			bars = newUpdater()
+			options = append(options, bars.options()...)
+		} else {
+			plain = newPlainProgress(os.Stdout)
+			options = append(options, plain.options()...)
+		}
+	}
+
+	return append(options, document.options()...), nil
+}
+
+// run displays the progress of the task and reports its errors, the exit
+// code is returned. The partial outputs are removed if the conversion is
+// interrupted by a signal.
+func run(t *pico.Task, task interface{}) int {
+	switch {
+	case events != nil:
+		t.Wait()
+		events.summary(t)
+	case silent, quiet:
+		t.Wait()
+	case entry:
+		for e := range t.Entries {
+			fmt.Printf("%s/%s %s\n", e[0], e[1], e[2])
+		}
+		t.Wait()
+	case bars != nil:
+		bar := Bar(task, bars)
+		t.Wait()
+		bar.Wait()
+	case plain != nil:
+		plain.attach(task)
+		t.Wait()
+	default:
+		t.Wait()
+	}
+
+	if sig := signals.signal(); sig != nil {
+		return interrupted(t, sig)
+	}
+
+	errs := t.Errors()
+	// errors are already printed as events in jsonl mode
+	for i := 0; events == nil && i < len(errs); i++ {
+		if quiet {
+			fmt.Fprintf(os.Stderr, "pdf2image: %v\n", errs[i])
+		} else {
+			fmt.Fprintf(os.Stderr, "%+v\n", errs[i])
+		}
+	}
+	if len(errs) > 0 {
+		return exitError
+	}
+	return exitOK
+}
+
+// reportConvert reports an error occurred before the conversion starts, it
+// is also printed as an event in jsonl mode
+func reportConvert(err error) int {
+	if events != nil {
+		events.fail(err)
+	}
+	return report(err)
+}
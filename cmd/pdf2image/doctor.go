@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/DeathKing/pico"
+)
+
+// usage:
+// pdf2image doctor [--poppler-path path]
+
+type utility struct {
+	name     string
+	required bool
+	usage    string
+}
+
+var utilities = []utility{
+	{"pdftoppm", true, "convert"},
+	{"pdftocairo", false, "convert --cairo"},
+	{"pdfinfo", true, "info, pages and every conversion"},
+	{"pdftotext", false, "text"},
+}
+
+// feature is an option that needs a minimal version of pdftoppm
+type feature struct {
+	flag    string
+	version []int
+}
+
+var features = []feature{
+	{"--quality, --optimize, --progressive", []int{0, 58}},
+	{"--hide-annotations", []int{0, 84}},
+}
+
+func runDoctor(args []string) int {
+	flag := newFlagSet("doctor", "[options]", "Check the poppler utilities used by pdf2image")
+	popplerPath := flag.String("poppler-path", "", "poppler binaries lookup path")
+
+	if code, ok := parse(flag, args, 0); !ok {
+		return code
+	}
+
+	options := []pico.CallOption{}
+	if *popplerPath != "" {
+		options = append(options, pico.WithPopplerPath(*popplerPath))
+	}
+
+	code := exitOK
+	pdftoppm := ""
+	for _, u := range utilities {
+		path, err := lookPath(u.name, *popplerPath)
+		if err != nil {
+			status := "missing (optional)"
+			if u.required {
+				status = "missing"
+				code = exitError
+			}
+			fmt.Printf("%-11s %s, needed by %s\n", u.name, status, u.usage)
+			continue
+		}
+
+		version, err := pico.GetPopplerVersion(u.name, options...)
+		if err != nil {
+			fmt.Printf("%-11s %s, unknown version: %v\n", u.name, path, err)
+			if u.required {
+				code = exitError
+			}
+			continue
+		}
+		if u.name == "pdftoppm" {
+			pdftoppm = version
+		}
+		fmt.Printf("%-11s %s (%s)\n", u.name, path, version)
+	}
+
+	if pdftoppm != "" {
+		for _, f := range features {
+			if !versionAtLeast(pdftoppm, f.version) {
+				fmt.Printf("warning: poppler %s or later is needed by %s\n", joinVersion(f.version), f.flag)
+			}
+		}
+	}
+	return code
+}
+
+// lookPath finds the utility in the poppler path, or in PATH if the poppler
+// path is not given
+func lookPath(name, popplerPath string) (string, error) {
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	if popplerPath != "" {
+		name = filepath.Join(popplerPath, name)
+	}
+	return exec.LookPath(name)
+}
+
+func versionAtLeast(version string, min []int) bool {
+	parts := strings.Split(version, ".")
+	for i, m := range min {
+		v := 0
+		if i < len(parts) {
+			v, _ = strconv.Atoi(parts[i])
+		}
+		if v != m {
+			return v > m
+		}
+	}
+	return true
+}
+
+func joinVersion(version []int) string {
+	parts := make([]string, len(version))
+	for i, v := range version {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/DeathKing/pico"
+)
+
+// usage:
+// pdf2image info [--json] [options] path-to-pdf-file...
+// pdf2image pages [options] path-to-pdf-file...
+
+func runInfo(args []string) int {
+	flag := newFlagSet("info", "[options] file...", "Print the document information reported by pdfinfo")
+	document := newDocumentFlags(flag)
+	asJSON := flag.Bool("json", false, "print the information as json")
+
+	if code, ok := parse(flag, args, 1); !ok {
+		return code
+	}
+
+	code := exitOK
+	all := map[string]map[string]string{}
+	for i, pdf := range flag.Args() {
+		infos, err := pico.GetInfo(pdf, document.options()...)
+		if err != nil {
+			code = report(fmt.Errorf("%s: %w", pdf, err))
+			continue
+		}
+
+		if *asJSON {
+			all[pdf] = infos
+			continue
+		}
+
+		if flag.NArg() > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s:\n", pdf)
+		}
+		printTable(infos)
+	}
+
+	if *asJSON {
+		var v interface{} = all
+		if flag.NArg() == 1 {
+			v = all[flag.Arg(0)]
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(v); err != nil {
+			return report(err)
+		}
+	}
+	return code
+}
+
+// printTable prints the information sorted by key with aligned values
+func printTable(infos map[string]string) {
+	keys := make([]string, 0, len(infos))
+	width := 0
+	for key := range infos {
+		keys = append(keys, key)
+		if len(key) > width {
+			width = len(key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Printf("%-*s  %s\n", width+1, key+":", infos[key])
+	}
+}
+
+func runPages(args []string) int {
+	flag := newFlagSet("pages", "[options] file...", "Print the page count of the documents")
+	document := newDocumentFlags(flag)
+
+	if code, ok := parse(flag, args, 1); !ok {
+		return code
+	}
+
+	code := exitOK
+	for _, pdf := range flag.Args() {
+		pages, err := pico.GetPagesCount(pdf, document.options()...)
+		if err != nil {
+			code = report(fmt.Errorf("%s: %w", pdf, err))
+			continue
+		}
+
+		if flag.NArg() > 1 {
+			fmt.Printf("%s: %d\n", pdf, pages)
+		} else {
+			fmt.Println(pages)
+		}
+	}
+	return code
+}
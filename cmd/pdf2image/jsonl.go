@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/DeathKing/pico"
+)
+
+// jsonlWriter prints the conversion events as JSON Lines, one object per
+// page-done, file-done and error event and a final summary. Durations are in
+// milliseconds.
+type jsonlWriter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	start   time.Time
+}
+
+type pageRecord struct {
+	Event    string  `json:"event"`
+	Time     string  `json:"time"`
+	File     string  `json:"file"`
+	Worker   int32   `json:"worker"`
+	Page     int32   `json:"page"`
+	Output   string  `json:"output"`
+	Duration float64 `json:"duration_ms"`
+}
+
+type fileRecord struct {
+	Event     string  `json:"event"`
+	Time      string  `json:"time"`
+	File      string  `json:"file"`
+	Worker    int32   `json:"worker"`
+	FirstPage int32   `json:"first_page"`
+	LastPage  int32   `json:"last_page"`
+	Pages     int32   `json:"pages"`
+	Duration  float64 `json:"duration_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type errorRecord struct {
+	Event  string `json:"event"`
+	Time   string `json:"time"`
+	File   string `json:"file,omitempty"`
+	Worker int32  `json:"worker"`
+	Page   int32  `json:"page"`
+	Error  string `json:"error"`
+}
+
+type summaryRecord struct {
+	Event          string  `json:"event"`
+	Time           string  `json:"time"`
+	State          string  `json:"state"`
+	Files          int     `json:"files"`
+	FailedFiles    int     `json:"failed_files"`
+	Pages          int32   `json:"pages"`
+	SkippedPages   int     `json:"skipped_pages"`
+	Errors         int     `json:"errors"`
+	BytesWritten   int64   `json:"bytes_written"`
+	Duration       float64 `json:"duration_ms"`
+	MeanLatency    float64 `json:"mean_page_ms"`
+	P90Latency     float64 `json:"p90_page_ms"`
+	MaxLatency     float64 `json:"max_page_ms"`
+	PagesPerSecond float64 `json:"pages_per_second"`
+}
+
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	return &jsonlWriter{encoder: json.NewEncoder(w), start: time.Now()}
+}
+
+func (w *jsonlWriter) write(v interface{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// the output is a pipe in most cases, there is nothing to do if the
+	// reader has gone
+	_ = w.encoder.Encode(v)
+}
+
+// options registers the hooks that print the events
+func (w *jsonlWriter) options() []pico.CallOption {
+	return []pico.CallOption{
+		pico.WithOnPageDone(func(e pico.PageEvent) {
+			w.write(pageRecord{"page-done", now(), e.File, e.WorkerId, e.Page, e.Output, ms(e.Duration)})
+		}),
+		pico.WithOnFileDone(func(e pico.FileEvent) {
+			r := fileRecord{"file-done", now(), e.File, e.WorkerId, e.FirstPage, e.LastPage, e.Pages, ms(e.Duration), ""}
+			if e.Err != nil {
+				r.Error = e.Err.Error()
+			}
+			w.write(r)
+		}),
+		pico.WithOnError(func(err *pico.ConversionError) {
+			w.write(errorRecord{"error", now(), err.File(), err.WorkerId(), err.Page(), err.Error()})
+		}),
+	}
+}
+
+// fail prints an error occurred before the conversion starts
+func (w *jsonlWriter) fail(err error) {
+	w.write(errorRecord{Event: "error", Time: now(), Worker: -1, Page: -1, Error: err.Error()})
+}
+
+// summary prints the counts and durations of the completed task
+func (w *jsonlWriter) summary(t *pico.Task) {
+	status := t.Status()
+	report := t.Report()
+
+	elapsed := time.Since(w.start)
+	r := summaryRecord{
+		Event:          "summary",
+		Time:           now(),
+		State:          status.State.String(),
+		Files:          len(report.Files),
+		Pages:          status.FinishedPages,
+		Errors:         len(t.Errors()),
+		BytesWritten:   status.BytesWritten,
+		Duration:       ms(elapsed),
+		MeanLatency:    ms(status.Stats.MeanLatency),
+		P90Latency:     ms(status.Stats.P90Latency),
+		MaxLatency:     ms(status.Stats.MaxLatency),
+		PagesPerSecond: float64(status.FinishedPages) / elapsed.Seconds(),
+	}
+	for _, file := range report.Files {
+		if file.Failed() {
+			r.FailedFiles++
+		}
+		r.SkippedPages += len(file.Skipped)
+	}
+	w.write(r)
+}
+
+func now() string {
+	return time.Now().Format(time.RFC3339Nano)
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -1,153 +1,188 @@
 package main
 
 import (
-	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
-	"strconv"
+	"strings"
 
 	"github.com/DeathKing/pico"
 )
 
 // usage:
-// pdf2image path-to-pdf-file
-// -j | --job
-// -wid | --worker-id
-//     append worker id when output file
-// -d | --dpi
-// -f | --first-page
-// -l | --last-page
-// -fmt | --format
-// -upw | --user-password
-// -opw | --oener-password
-// -t | --timeout
-// -opt | --optimize
-// -o | --output-folder
-//    set output folder name
-// --slient
-//    do not display any infomation
-// --entry
-// --bar (default)
-//    show conversion progress bar
-// -gray | --grayscale
-// -trans | --transparent
-
-var (
-	dpi          int
-	worker       int
-	firstPage    int
-	lastPage     int
-	outputFolder string
-	outputFormat string
-
-	appendWorkerId bool
-
-	nameFn = func(pdf string, index, first, last int32) string {
-		wid := ""
-		if appendWorkerId {
-			wid = strconv.Itoa(int(index))
-		}
-
-		fileName := filepath.Base(pdf)
-		return fmt.Sprintf("%s-%s", fileName[:len(fileName)-len(filepath.Ext(fileName))], wid)
-	}
+// pdf2image <command> [options] [arguments]
+//
+// commands:
+//   convert   convert pdf files to images
+//   info      print the document information
+//   pages     print the page count
+//   text      extract the text
+//   doctor    check poppler binaries and versions
+//
+// `pdf2image help <command>` or `pdf2image <command> -h` prints the help of a
+// command. For compatibility, `pdf2image [options] file...` is `convert`.
+
+// exit codes
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
 )
 
-func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	usage := "output dpi"
-	flag.IntVar(&dpi, "d", 72, usage)
-	flag.IntVar(&dpi, "dpi", 72, usage)
+type command struct {
+	name  string
+	brief string
+	run   func(args []string) int
+}
 
-	usage = "worker count"
-	flag.IntVar(&worker, "j", -1, usage)
-	flag.IntVar(&worker, "job", -1, usage)
+var commands = []*command{
+	{"convert", "convert pdf files to images", runConvert},
+	{"info", "print the document information", runInfo},
+	{"pages", "print the page count", runPages},
+	{"text", "extract the text", runText},
+	{"doctor", "check poppler binaries and versions", runDoctor},
+}
 
-	usage = "append worker id in filename"
-	flag.BoolVar(&appendWorkerId, "wid", false, usage)
-	flag.BoolVar(&appendWorkerId, "worker-id", false, usage)
+func main() {
+	os.Exit(dispatch(os.Args[1:]))
+}
 
-	usage = "fisrt page"
-	flag.IntVar(&firstPage, "f", -1, usage)
-	flag.IntVar(&firstPage, "first-page", -1, usage)
+func dispatch(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return exitUsage
+	}
 
-	usage = "last page"
-	flag.IntVar(&lastPage, "l", -1, usage)
-	flag.IntVar(&lastPage, "last-page", -1, usage)
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		if len(args) > 1 {
+			if cmd := lookup(args[1]); cmd != nil {
+				return cmd.run([]string{"-h"})
+			}
+			fmt.Fprintf(os.Stderr, "pdf2image: unknown command %q\n", args[1])
+			return exitUsage
+		}
+		usage(os.Stdout)
+		return exitOK
+	}
 
-	usage = "output folder"
-	flag.StringVar(&outputFolder, "o", ".", usage)
-	flag.StringVar(&outputFolder, "output-folder", ".", usage)
+	if cmd := lookup(args[0]); cmd != nil {
+		return cmd.run(args[1:])
+	}
 
-	usage = "output format (jpeg, png)"
-	flag.StringVar(&outputFormat, "fmt", "jpeg", usage)
-	flag.StringVar(&outputFormat, "format", "jpeg", usage)
+	// pdf2image [options] file... converts the files as before
+	if _, err := os.Stat(args[0]); err == nil || strings.HasPrefix(args[0], "-") || isList(args[0]) {
+		return runConvert(args)
+	}
 
-	flag.Parse()
+	fmt.Fprintf(os.Stderr, "pdf2image: unknown command %q\n", args[0])
+	usage(os.Stderr)
+	return exitUsage
+}
 
-	switch flag.NArg() {
-	case 0:
+func lookup(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
 
-		flag.PrintDefaults()
-		os.Exit(0)
-	case 1:
-		pdf := flag.Arg(0)
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: pdf2image <command> [options] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s%s\n", cmd.name, cmd.brief)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'pdf2image help <command>' for the options of a command.")
+}
 
-		info, err := os.Stat(pdf)
-		if err != nil {
-			fmt.Printf("%s", err)
-			os.Exit(1)
-		}
+// newFlagSet creates the flag set of a command, `synopsis` is the usage line
+// without the program and command name
+func newFlagSet(name, synopsis, brief string) *flag.FlagSet {
+	fs := flag.NewFlagSet("pdf2image "+name, flag.ContinueOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "usage: pdf2image %s %s\n\n%s.\n\noptions:\n", name, synopsis, brief)
+		fs.PrintDefaults()
+	}
+	return fs
+}
 
-		if !info.IsDir() {
-			convertSingle(ctx, pdf)
-			return
+// parse parses the arguments of a command, ok is false if the command should
+// exit with `code` at once
+func parse(fs *flag.FlagSet, args []string, minArgs int) (code int, ok bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
 		}
+		return exitUsage, false
 	}
 
-	convertBatch(ctx, flag.Args())
+	if fs.NArg() < minArgs {
+		fmt.Fprintf(fs.Output(), "%s: missing file argument\n", fs.Name())
+		fs.Usage()
+		return exitUsage, false
+	}
+	return exitOK, true
+}
 
+// documentFlags are the flags shared by the commands that open documents
+type documentFlags struct {
+	userPw      string
+	ownerPw     string
+	popplerPath string
 }
 
-func convertSingle(ctx context.Context, pdf string) {
-	task, _ := pico.Convert(pdf,
-		pico.WithDpi(dpi),
-		pico.WithFormat(outputFormat),
-		pico.WithContext(ctx),
-		pico.WithOutputFileFn(nameFn),
-		pico.WithJob(worker),
-		pico.WithOutputFolder(outputFolder))
+func newDocumentFlags(fs *flag.FlagSet) *documentFlags {
+	f := &documentFlags{}
+
+	usage := "user password"
+	fs.StringVar(&f.userPw, "upw", "", usage)
+	fs.StringVar(&f.userPw, "user-password", "", usage)
 
-	bar := Bar(task)
+	usage = "owner password"
+	fs.StringVar(&f.ownerPw, "opw", "", usage)
+	fs.StringVar(&f.ownerPw, "owner-password", "", usage)
+
+	fs.StringVar(&f.popplerPath, "poppler-path", "", "poppler binaries lookup path")
+	return f
+}
 
-	task.Wait()
-	for _, err := range task.Errors() {
-		fmt.Fprintf(os.Stderr, "%+v\n", err)
+func (f *documentFlags) options() []pico.CallOption {
+	options := []pico.CallOption{}
+	if f.userPw != "" {
+		options = append(options, pico.WithUserPw(f.userPw))
 	}
-	bar.Wait()
+	if f.ownerPw != "" {
+		options = append(options, pico.WithOwnerPw(f.ownerPw))
+	}
+	if f.popplerPath != "" {
+		options = append(options, pico.WithPopplerPath(f.popplerPath))
+	}
+	return options
 }
 
-func convertBatch(ctx context.Context, pdfs []string) {
-	task, _ := pico.ConvertFiles(pico.FromMultiSource(pdfs),
-		pico.WithDpi(dpi),
-		pico.WithFormat(outputFormat),
-		pico.WithContext(ctx),
-		pico.WithOutputFileFn(nameFn),
-		pico.WithFirstPage(firstPage),
-		pico.WithLastPage(lastPage),
-		pico.WithJob(worker),
-		pico.WithOutputFolder(outputFolder))
+type usageError string
 
-	bar := Bar(task)
+func (e usageError) Error() string {
+	return string(e)
+}
 
-	task.Wait()
-	bar.Wait()
+// report prints the error and returns the exit code, invalid arguments exit
+// with exitUsage
+func report(err error) int {
+	fmt.Fprintf(os.Stderr, "pdf2image: %v\n", err)
 
-	for _, err := range task.Errors() {
-		fmt.Fprintf(os.Stderr, "%+v\n", err)
+	var usage usageError
+	var argumentError *pico.WrongArgumentError
+	if errors.As(err, &usage) || errors.As(err, &argumentError) {
+		return exitUsage
 	}
+	return exitError
 }
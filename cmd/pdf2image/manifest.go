@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/DeathKing/pico"
+)
+
+// manifestEntry is a file of the manifest with its own options, zero values
+// fall back to the command line options
+type manifestEntry struct {
+	File          string `json:"file"`
+	Dpi           int    `json:"dpi"`
+	FirstPage     int    `json:"first_page"`
+	LastPage      int    `json:"last_page"`
+	Format        string `json:"format"`
+	UserPassword  string `json:"user_password"`
+	OwnerPassword string `json:"owner_password"`
+	OutputFolder  string `json:"output_folder"`
+}
+
+func (e *manifestEntry) item() pico.Item {
+	options := []pico.CallOption{}
+	if e.Dpi != 0 {
+		options = append(options, pico.WithDpi(e.Dpi))
+	}
+	if e.FirstPage != 0 {
+		options = append(options, pico.WithFirstPage(e.FirstPage))
+	}
+	if e.LastPage != 0 {
+		options = append(options, pico.WithLastPage(e.LastPage))
+	}
+	if e.Format != "" {
+		options = append(options, pico.WithFormat(e.Format))
+	}
+	if e.UserPassword != "" {
+		options = append(options, pico.WithUserPw(e.UserPassword))
+	}
+	if e.OwnerPassword != "" {
+		options = append(options, pico.WithOwnerPw(e.OwnerPassword))
+	}
+	if e.OutputFolder != "" {
+		options = append(options, pico.WithOutputFolder(e.OutputFolder))
+	}
+	return pico.Item{File: e.File, Options: options}
+}
+
+// manifestFolders are the output folders of the files given by the manifest,
+// the partial outputs in them are removed on interrupt
+var manifestFolders = map[string][]string{}
+
+// loadManifest reads the files and their options from a JSON array of
+// objects, or from a CSV file with a header row. Both use the json keys of
+// manifestEntry, only `file` is required.
+func loadManifest(path string) ([]pico.Item, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []manifestEntry
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(f)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&entries); err != nil {
+			return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+		}
+	} else if entries, err = readCSVManifest(f); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+
+	items := make([]pico.Item, 0, len(entries))
+	for i, entry := range entries {
+		if entry.File == "" {
+			return nil, fmt.Errorf("invalid manifest %s: entry %d has no file", path, i+1)
+		}
+		items = append(items, entry.item())
+		if entry.OutputFolder != "" {
+			manifestFolders[entry.File] = append(manifestFolders[entry.File], entry.OutputFolder)
+		}
+	}
+	return items, nil
+}
+
+func readCSVManifest(r io.Reader) ([]manifestEntry, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []manifestEntry{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entry := manifestEntry{}
+		for i, column := range header {
+			if err := entry.set(strings.TrimSpace(column), strings.TrimSpace(record[i])); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func (e *manifestEntry) set(column, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	var err error
+	switch column {
+	case "file":
+		e.File = value
+	case "dpi":
+		e.Dpi, err = strconv.Atoi(value)
+	case "first_page":
+		e.FirstPage, err = strconv.Atoi(value)
+	case "last_page":
+		e.LastPage, err = strconv.Atoi(value)
+	case "format":
+		e.Format = value
+	case "user_password":
+		e.UserPassword = value
+	case "owner_password":
+		e.OwnerPassword = value
+	case "output_folder":
+		e.OutputFolder = value
+	default:
+		return fmt.Errorf("unknown column %q", column)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", column, value)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/DeathKing/pico"
+)
+
+// _plainInterval is the minimal interval between two plain progress lines
+const _plainInterval = 2 * time.Second
+
+// plainProgress prints the progress as plain lines, it is used when the output
+// is not a terminal, e.g. piped or in CI logs. It is registered as hooks
+// before the task starts so no event is missed, events received before the
+// task is attached are printed when it is.
+type plainProgress struct {
+	w io.Writer
+
+	mu      sync.Mutex
+	t       *pico.Task
+	batch   bool
+	last    time.Time
+	pending []pico.Event
+}
+
+func newPlainProgress(w io.Writer) *plainProgress {
+	return &plainProgress{w: w}
+}
+
+// options registers the hooks that print the progress
+func (p *plainProgress) options() []pico.CallOption {
+	return []pico.CallOption{
+		pico.WithOnPageDone(func(e pico.PageEvent) {
+			p.handle(pico.Event{Kind: pico.EventPageDone, Time: time.Now(), Page: &e})
+		}),
+		pico.WithOnFileDone(func(e pico.FileEvent) {
+			p.handle(pico.Event{Kind: pico.EventFileDone, Time: time.Now(), File: &e})
+		}),
+		pico.WithOnTaskDone(func(e pico.TaskEvent) {
+			p.handle(pico.Event{Kind: pico.EventTaskDone, Time: time.Now(), Task: &e})
+		}),
+	}
+}
+
+// attach sets the task whose progress is printed and prints the events
+// received so far
+func (p *plainProgress) attach(task interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch t := task.(type) {
+	case *pico.SingleTask:
+		p.t = &t.Task
+	case *pico.BatchTask:
+		p.t, p.batch = &t.Task, true
+	default:
+		panic("unknown task type")
+	}
+
+	for _, e := range p.pending {
+		p.print(e)
+	}
+	p.pending = nil
+}
+
+func (p *plainProgress) handle(e pico.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.t == nil {
+		p.pending = append(p.pending, e)
+		return
+	}
+	p.print(e)
+}
+
+func (p *plainProgress) print(e pico.Event) {
+	switch e.Kind {
+	case pico.EventPageDone:
+		if e.Time.Sub(p.last) >= _plainInterval {
+			p.progress(e.Time)
+		}
+	case pico.EventFileDone:
+		// the file is split into parts for SingleTask
+		if p.batch {
+			p.fileDone(e.File)
+		}
+	case pico.EventTaskDone:
+		p.taskDone(e.Task)
+	}
+}
+
+func (p *plainProgress) progress(now time.Time) {
+	p.last = now
+
+	pages := p.t.Pages
+	line := fmt.Sprintf("progress: %d/%d pages", pages.Finished(), pages.Total())
+	if total := pages.Total(); total > 0 {
+		line += fmt.Sprintf(" (%d%%)", 100*int(pages.Finished())/int(total))
+	}
+	if p.batch {
+		line += fmt.Sprintf(", %d/%d files", p.t.Finished(), p.t.Total())
+	}
+	if stats := pages.Stats(); stats.Throughput > 0 {
+		line += fmt.Sprintf(", %.1f p/s, ETA %s", stats.Throughput, stats.ETA.Round(time.Second))
+	}
+	fmt.Fprintln(p.w, line)
+}
+
+func (p *plainProgress) fileDone(e *pico.FileEvent) {
+	if e.Err != nil {
+		fmt.Fprintf(p.w, "failed: %s after %d pages: %v\n", e.File, e.Pages, e.Err)
+		return
+	}
+	fmt.Fprintf(p.w, "done: %s, %d pages in %s\n", e.File, e.Pages, e.Duration.Round(time.Millisecond))
+}
+
+func (p *plainProgress) taskDone(e *pico.TaskEvent) {
+	status := "finished"
+	if p.t.Aborted() {
+		status = "aborted"
+	}
+	fmt.Fprintf(p.w, "%s: %d pages in %s, errors: %d\n",
+		status, e.Pages, e.Duration.Round(time.Millisecond), len(e.Errors))
+}
+
+// isTerminal reports whether the file is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/DeathKing/pico"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlainProgressBeforeAttach(t *testing.T) {
+	var buf bytes.Buffer
+	p := newPlainProgress(&buf)
+
+	// the task may finish before it is attached
+	p.handle(pico.Event{Kind: pico.EventFileDone, Time: time.Now(),
+		File: &pico.FileEvent{File: "a.pdf", Pages: 3, Duration: time.Second}})
+	p.handle(pico.Event{Kind: pico.EventTaskDone, Time: time.Now(),
+		Task: &pico.TaskEvent{Pages: 3, Duration: time.Second}})
+	assert.Empty(t, buf.String())
+
+	p.attach(&pico.BatchTask{})
+	assert.Equal(t, "done: a.pdf, 3 pages in 1s\nfinished: 3 pages in 1s, errors: 0\n", buf.String())
+}
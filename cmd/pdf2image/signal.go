@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/DeathKing/pico"
+)
+
+// interrupter cancels the conversion on the first SIGINT or SIGTERM, and
+// forces the exit on the second one
+type interrupter struct {
+	mu     sync.Mutex
+	ch     chan os.Signal
+	cancel context.CancelFunc
+	sig    os.Signal
+}
+
+func handleSignals(cancel context.CancelFunc) *interrupter {
+	i := &interrupter{ch: make(chan os.Signal, 2), cancel: cancel}
+	signal.Notify(i.ch, os.Interrupt, syscall.SIGTERM)
+	go i.wait()
+	return i
+}
+
+func (i *interrupter) wait() {
+	sig, ok := <-i.ch
+	if !ok {
+		return
+	}
+
+	i.mu.Lock()
+	i.sig = sig
+	i.mu.Unlock()
+
+	fmt.Fprintf(os.Stderr, "\npdf2image: %s received, stopping the workers (repeat to force exit)\n", signame(sig))
+	i.cancel()
+
+	if sig, ok := <-i.ch; ok {
+		fmt.Fprintf(os.Stderr, "pdf2image: %s received, exit without cleanup\n", signame(sig))
+		os.Exit(exitCode(sig))
+	}
+}
+
+// stop restores the default signal behavior
+func (i *interrupter) stop() {
+	signal.Stop(i.ch)
+	close(i.ch)
+}
+
+// signal returns the signal that interrupted the conversion, or nil
+func (i *interrupter) signal() os.Signal {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.sig
+}
+
+func signame(sig os.Signal) string {
+	switch sig {
+	case os.Interrupt:
+		return "SIGINT"
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	}
+	return sig.String()
+}
+
+// exitCode follows the shell convention, 128 plus the signal number
+func exitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return exitError
+}
+
+// interrupted removes the partial outputs of the interrupted task and prints
+// what has been finished, the exit code is returned
+func interrupted(t *pico.Task, sig os.Signal) int {
+	report := t.Report()
+
+	completed, pages, removed := 0, 0, 0
+	for pdf, file := range report.Files {
+		pages += len(file.Succeeded)
+		if len(file.Skipped) == 0 && !file.Failed() {
+			completed++
+			continue
+		}
+
+		folders := manifestFolders[pdf]
+		if len(folders) == 0 {
+			folders = []string{outputFolder}
+		}
+		removed += removePartialOutputs(pdf, file.Skipped, folders, started)
+	}
+
+	fmt.Fprintf(os.Stderr, "pdf2image: interrupted by %s, %d of %d files completed, %d pages converted, %d partial outputs removed\n",
+		signame(sig), completed, len(report.Files), pages, removed)
+	return exitCode(sig)
+}
+
+// removePartialOutputs removes the outputs of the skipped pages of the file,
+// which are left by the killed poppler utilities. Outputs are named like
+// `name-[worker id]-page.ext` by nameFn, the page number may be zero padded.
+// Files modified before `since` are kept, they are not written by this run.
+func removePartialOutputs(pdf string, pages []int32, folders []string, since time.Time) int {
+	skipped := map[int]bool{}
+	for _, page := range pages {
+		skipped[int(page)] = true
+	}
+
+	stem := filepath.Base(pdf)
+	stem = stem[:len(stem)-len(filepath.Ext(stem))] + "-"
+
+	removed := 0
+	for _, folder := range folders {
+		matches, _ := filepath.Glob(filepath.Join(folder, globEscape(stem)+"*"))
+		for _, match := range matches {
+			name := strings.TrimPrefix(filepath.Base(match), stem)
+			name = name[:len(name)-len(filepath.Ext(name))]
+
+			sep := strings.LastIndex(name, "-")
+			if sep < 0 || !isDigits(name[:sep]) {
+				continue
+			}
+			page, err := strconv.Atoi(name[sep+1:])
+			if err != nil || !skipped[page] {
+				continue
+			}
+
+			info, err := os.Stat(match)
+			if err != nil || !info.Mode().IsRegular() || info.ModTime().Before(since) {
+				continue
+			}
+			if err := os.Remove(match); err == nil {
+				removed++
+			}
+		}
+	}
+	return removed
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// globEscape escapes the meta characters of filepath.Match
+func globEscape(s string) string {
+	replacer := strings.NewReplacer("*", "[*]", "?", "[?]", "[", "[[]")
+	return replacer.Replace(s)
+}
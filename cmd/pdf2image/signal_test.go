@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemovePartialOutputs(t *testing.T) {
+	since := time.Now()
+	folder, manifestFolder := t.TempDir(), t.TempDir()
+
+	write := func(path string, modified time.Time) string {
+		assert.NoError(t, ioutil.WriteFile(path, []byte("x"), 0644))
+		assert.NoError(t, os.Chtimes(path, modified, modified))
+		return path
+	}
+	later := since.Add(time.Second)
+	earlier := since.Add(-time.Hour)
+
+	partial := write(filepath.Join(folder, "a.b--1.ppm"), later)
+	previous := write(filepath.Join(folder, "a.b--2.ppm"), earlier)
+	converted := write(filepath.Join(folder, "a.b--3.ppm"), later)
+	noExt := write(filepath.Join(folder, "a.b-"), later)
+	other := write(filepath.Join(folder, "a.b-x.ppm"), later)
+	partialByWorker := write(filepath.Join(manifestFolder, "a.b-0-04.ppm"), later)
+
+	var removed int
+	assert.NotPanics(t, func() {
+		removed = removePartialOutputs(filepath.Join("docs", "a.b.pdf"), []int32{1, 2, 4},
+			[]string{folder, manifestFolder}, since)
+	})
+	assert.Equal(t, 2, removed)
+
+	for _, path := range []string{partial, partialByWorker} {
+		assert.NoFileExists(t, path)
+	}
+	for _, path := range []string{previous, converted, noExt, other} {
+		assert.FileExists(t, path)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/DeathKing/pico"
+)
+
+// source provides the files given by the arguments, `-` reads a file list
+// from stdin and `@list.txt` reads it from list.txt. Other arguments are
+// walked as files, folders or glob patterns.
+type source struct {
+	ch   chan string
+	done chan struct{}
+
+	mu     sync.Mutex
+	errs   []error
+	closed bool
+}
+
+func isList(arg string) bool {
+	return arg == "-" || strings.HasPrefix(arg, "@")
+}
+
+func newSource(args []string, delim byte, options ...pico.WalkOption) *source {
+	s := &source{ch: make(chan string), done: make(chan struct{})}
+
+	go func() {
+		defer close(s.ch)
+		for _, arg := range args {
+			if !isList(arg) {
+				if !s.drain(pico.FromWalk([]string{arg}, options...)) {
+					return
+				}
+				continue
+			}
+
+			if arg == "-" {
+				if !s.drain(pico.FromReader(os.Stdin, delim)) {
+					return
+				}
+				continue
+			}
+
+			f, err := os.Open(arg[1:])
+			if err != nil {
+				s.error(err)
+				continue
+			}
+			ok := s.drain(pico.FromReader(f, delim))
+			f.Close()
+			if !ok {
+				return
+			}
+		}
+	}()
+
+	return s
+}
+
+// drain forwards the files of `p`, false is returned if the source is closed
+func (s *source) drain(p pico.PdfProvider) bool {
+	defer p.Close()
+
+	for pdf := range p.Source() {
+		select {
+		case s.ch <- pdf:
+		case <-s.done:
+			return false
+		}
+	}
+	if err := p.Err(); err != nil {
+		s.error(err)
+	}
+	return true
+}
+
+func (s *source) error(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if multi, ok := err.(*pico.MultiError); ok {
+		s.errs = append(s.errs, multi.Errors...)
+		return
+	}
+	s.errs = append(s.errs, err)
+}
+
+func (s *source) Source() <-chan string {
+	return s.ch
+}
+
+// Count is unknown until all the lists are read
+func (s *source) Count() int {
+	return -1
+}
+
+func (s *source) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch len(s.errs) {
+	case 0:
+		return nil
+	case 1:
+		return s.errs[0]
+	default:
+		return &pico.MultiError{Errors: append([]error{}, s.errs...)}
+	}
+}
+
+func (s *source) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return pico.ErrProviderClosed
+	}
+	s.closed = true
+	close(s.done)
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/DeathKing/pico"
+)
+
+// usage:
+// pdf2image text [-f first] [-l last] [--layout] [-o output] path-to-pdf-file
+
+func runText(args []string) int {
+	flag := newFlagSet("text", "[options] file", "Extract the text of the document with pdftotext")
+	document := newDocumentFlags(flag)
+
+	var firstPage, lastPage int
+	var output string
+
+	usage := "first page"
+	flag.IntVar(&firstPage, "f", -1, usage)
+	flag.IntVar(&firstPage, "first-page", -1, usage)
+
+	usage = "last page"
+	flag.IntVar(&lastPage, "l", -1, usage)
+	flag.IntVar(&lastPage, "last-page", -1, usage)
+
+	usage = "write the text to this file instead of stdout"
+	flag.StringVar(&output, "o", "", usage)
+	flag.StringVar(&output, "output", "", usage)
+
+	layout := flag.Bool("layout", false, "keep the physical layout of the text")
+
+	if code, ok := parse(flag, args, 1); !ok {
+		return code
+	}
+	if flag.NArg() > 1 {
+		return report(usageError("text accepts only one file"))
+	}
+
+	options := append(document.options(), pico.WithFirstPage(firstPage), pico.WithLastPage(lastPage))
+	if *layout {
+		options = append(options, pico.WithTextLayout())
+	}
+
+	text, err := pico.GetText(flag.Arg(0), options...)
+	if err != nil {
+		return report(err)
+	}
+
+	if output == "" {
+		fmt.Print(text)
+		return exitOK
+	}
+	if err := ioutil.WriteFile(output, []byte(text), 0644); err != nil {
+		return report(err)
+	}
+	return exitOK
+}
@@ -14,13 +14,11 @@ func Convert(pdf string, options ...CallOption) (*SingleTask, error) {
 	}
 
 	// 1. page calculation
-	pages, err := GetPagesCount(pdf, options...)
+	totalPage, infos, err := p.pdfInfo(pdf)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	totalPage := int32(pages)
-
 	if p.singleFile {
 		p.firstPage = 1
 		p.lastPage = 1
@@ -55,6 +53,10 @@ func Convert(pdf string, options ...CallOption) (*SingleTask, error) {
 
 	p.minPagesPerWorker = p.pageCount / p.job
 
+	if err := p.checkBudget(pdf, totalPage, infos, 0); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
 	task := newSingleTask(p)
 
 	return task, task.Start(pdf)
@@ -62,7 +64,8 @@ func Convert(pdf string, options ...CallOption) (*SingleTask, error) {
 
 // ConvertFiles converts multiple PDF files to images
 //
-// files could be type `[]string`, `chan string`, or `PdfProvider`
+// files could be type `string` (a glob pattern), `[]string`, `chan string`,
+// `[]Item`, `chan Item`, or `PdfProvider`. Items carry their own options.
 func ConvertFiles(files interface{}, options ...CallOption) (*BatchTask, error) {
 	p := defaultConvertFilesCallOption()
 
@@ -80,6 +83,25 @@ func ConvertFiles(files interface{}, options ...CallOption) (*BatchTask, error)
 	return task, task.Start(provider)
 }
 
+// OpenBatch starts a BatchTask without files, files are submitted by
+// `Submit` while the task is running. The task finishes after
+// `CloseSubmissions()` is called and the submitted files are done. Entries
+// of the files are delivered by their submissions rather than the task, and
+// `WithPreflight` is ignored.
+func OpenBatch(options ...CallOption) (*BatchTask, error) {
+	p := defaultConvertFilesCallOption()
+
+	if err := p.apply(options...); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	p.preflight = false
+	p.job = determineWorkerCount(p.job, -1)
+
+	task := newBatchTask(p)
+
+	return task, task.Start(newSubmissionQueue(p.ctx))
+}
+
 func determineWorkerCount(set, need int32) int32 {
 	switch {
 	case set > 0:
@@ -182,3 +182,53 @@ func TestStrictMode(t *testing.T) {
 		})
 	}
 }
+
+func TestOrderedEntries(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+
+	t.Run("SingleTask", func(t *testing.T) {
+		pdf := fakePDF(t, dir, "ordered.pdf", 40, 0.01)
+		task, err := Convert(pdf,
+			WithPopplerPath(poppler),
+			WithOutputFolder(t.TempDir()),
+			WithJob(4),
+			WithOrderedEntries(2),
+		)
+		assert.NoError(t, err, "conversion task initialization should not failed")
+
+		entries := task.WaitAndCollect()
+		assert.Len(t, entries, 40)
+		for i, entry := range entries {
+			assert.Equal(t, strconv.Itoa(i+1), entry[0])
+		}
+	})
+
+	t.Run("BatchTask", func(t *testing.T) {
+		files := []string{
+			fakePDF(t, dir, "a.pdf", 12, 0.01),
+			fakePDF(t, dir, "b.pdf", 7, 0),
+			fakePDF(t, dir, "c.pdf", 9, 0.005),
+		}
+		task, err := ConvertFiles(files,
+			WithPopplerPath(poppler),
+			WithOutputFolder(t.TempDir()),
+			WithJob(3),
+			WithOrderedEntries(1),
+		)
+		assert.NoError(t, err, "conversion task initialization should not failed")
+
+		entries := task.WaitAndCollect()
+		assert.Len(t, entries, 28)
+
+		// pages of a file must be contiguous and in order
+		for i := 1; i < len(entries); i++ {
+			prev, cur := entries[i-1], entries[i]
+			if cur[0] != "1" {
+				page, _ := strconv.Atoi(prev[0])
+				assert.Equal(t, strconv.Itoa(page+1), cur[0])
+				assert.Equal(t, prev[3], cur[3])
+			}
+		}
+	})
+}
@@ -2,76 +2,247 @@ package pico
 
 import (
 	"bufio"
+	"context"
 	"io"
 	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 type Convertor struct {
 	Progress
+	lifecycle
 
-	t   *Task
-	id  int32
+	t  *Task
+	id int32
+
+	// mu guards cmd, converrs and submission, which are accessed by the
+	// convertor, its parser and the readers
+	mu  sync.Mutex
 	cmd *exec.Cmd
 
 	// converrs is a list of errors that occurred during the conversion.
 	converrs []*ConversionError
 
-	done chan interface{}
+	// submission is the handle of current file if it is submitted by
+	// `BatchTask.Submit`
+	submission *Submission
+
+	// first and last are the page range of current file
+	first int32
+	last  int32
+
+	// fileStartedAt and pageStartedAt are used to measure the durations of
+	// current file and page
+	fileStartedAt time.Time
+	pageStartedAt time.Time
+
+	// fileErrors is the index in `converrs` of the first error of current file
+	fileErrors int
+
+	// fileBytes is the bytes written for current file, overBudget is set
+	// once it exceeds the file budget
+	fileBytes  int64
+	overBudget bool
+
+	// fileParams are the parameters of current file in batch mode if the
+	// file has its own options
+	fileParams *Parameters
+
+	// cmdCtx is the context of current command, which could be cancelled by
+	// stopCmd without cancelling the task
+	cmdCtx  context.Context
+	stopCmd context.CancelFunc
 
-	aborted bool
+	// spans of current file, subprocess and page
+	fileCtx  context.Context
+	procCtx  context.Context
+	fileSpan Span
+	procSpan Span
+	pageSpan Span
+
+	// segment is the entry stream of the file (or part of the file) being
+	// converted, only used when entries are delivered in order
+	segment chan []string
+
+	done chan interface{}
 }
 
 // spwanCmdForPipe spwans an `exec.Cmd` for convererting the pdf from `first` to `last`,
 //
-func (c *Convertor) spwanCmdForPipe(pdf string, first, last int32) (io.ReadCloser, error) {
-	p := c.t.params
+func (c *Convertor) spwanCmdForPipe(pdf string, first, last int32) (*exec.Cmd, io.ReadCloser, error) {
+	p := c.params()
 	command := p.buildCommand(pdf, c.id, first, last)
-	c.cmd = buildCmd(p.ctx, p.popplerPath, command)
+	c.cmdCtx, c.stopCmd = context.WithCancel(p.ctx)
+	cmd := buildCmd(c.cmdCtx, p.popplerPath, command)
+	c.log(LevelDebug, "spawn command", Field{"file", pdf}, Field{"command", command})
+
+	c.fileCtx, c.fileSpan = p.tracer.Start(c.t.traceCtx, SpanFile,
+		append(p.spanAttributes(last-first+1),
+			Attribute{"file", pdf}, Attribute{"worker", c.id},
+			Attribute{"first_page", first}, Attribute{"last_page", last})...)
+	c.procCtx, c.procSpan = p.tracer.Start(c.fileCtx, SpanSubprocess,
+		Attribute{"command", strings.Join(redactCommand(command), " ")}, Attribute{"worker", c.id})
 
 	// cmd.Wait() will close the pipe
-	pipe, err := c.cmd.StderrPipe()
+	pipe, err := cmd.StderrPipe()
 	if err != nil {
-		return nil, errors.WithStack(err)
+		c.releaseCmd()
+		c.endSpans(err)
+		return nil, nil, errors.WithStack(err)
 	}
 
-	if err = c.cmd.Start(); err != nil {
-		return nil, errors.WithStack(err)
+	if err = cmd.Start(); err != nil {
+		c.releaseCmd()
+		c.endSpans(err)
+		c.t.metrics.spawnFailed()
+		c.log(LevelError, "failed to spawn command", Field{"file", pdf}, Field{"error", err})
+		return nil, nil, errors.WithStack(err)
 	}
 
-	return pipe, nil
+	c.setCmd(cmd)
+	return cmd, pipe, nil
+}
+
+// releaseCmd releases the context of current command
+func (c *Convertor) releaseCmd() {
+	if c.stopCmd != nil {
+		c.stopCmd()
+		c.stopCmd = nil
+	}
 }
 
+// setCmd sets the command currently running, nil means the convertor is
+// not running any command
+func (c *Convertor) setCmd(cmd *exec.Cmd) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cmd = cmd
+}
+
+// Errors returns a copy of the errors occurred so far, it is safe to be
+// called during the conversion.
 func (c *Convertor) Errors() []*ConversionError {
-	return c.converrs
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*ConversionError{}, c.converrs...)
 }
 
 func (c *Convertor) Error() (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if len(c.converrs) > 0 {
 		err = c.converrs[0].err
 	}
 	return
 }
 
+// errorCount returns the number of errors occurred so far
+func (c *Convertor) errorCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.converrs)
+}
+
 // receiveError 可能存在跨pdf error 的情况么？
 func (c *Convertor) receiveError(err error, page int32) bool {
 	if err == nil {
 		return true
 	}
 
-	c.converrs = append(c.converrs, &ConversionError{
-		pdf:      c.pdf,
+	pdf := c.Filename()
+	converr := &ConversionError{
+		pdf:      pdf,
 		page:     page,
 		workerId: c.id,
 		err:      err,
-	})
+	}
+	c.mu.Lock()
+	c.converrs = append(c.converrs, converr)
+	submission := c.submission
+	c.mu.Unlock()
+	if submission != nil {
+		submission.receiveError(converr)
+	}
+	c.log(LevelError, "conversion error", Field{"file", pdf}, Field{"page", page}, Field{"error", err})
+	c.t.emitError(converr)
 
 	// if we're in `strict` mode, break further execution by return false
-	return !c.t.params.strict
+	return !c.params().strict
+}
+
+// params returns the parameters of the file being converted, which may have
+// its own options
+func (c *Convertor) params() *Parameters {
+	if c.fileParams != nil {
+		return c.fileParams
+	}
+	return c.t.params
+}
+
+// startPageSpan starts the span of the page being rendered
+func (c *Convertor) startPageSpan(page int32) {
+	_, c.pageSpan = c.t.params.tracer.Start(c.procCtx, SpanPage,
+		Attribute{"page", page}, Attribute{"worker", c.id})
+}
+
+// endSpans ends all the spans of current file
+func (c *Convertor) endSpans(err error) {
+	endSpan(c.pageSpan, nil)
+	endSpan(c.procSpan, nil)
+	endSpan(c.fileSpan, err)
+	c.pageSpan, c.procSpan, c.fileSpan = nil, nil, nil
+}
+
+// log logs a record with the task and worker fields
+func (c *Convertor) log(level Level, msg string, fields ...Field) {
+	fields = append([]Field{{"task", c.t.id}, {"worker", c.id}}, fields...)
+	c.t.params.logger.Log(level, msg, fields...)
+}
+
+// receiveFileError records an error that prevents the whole file from being
+// converted, the file is reported as done with the error.
+func (c *Convertor) receiveFileError(pdf string, err error) {
+	c.setInit(pdf, 0, -1)
+	c.receiveError(err, -1)
+
+	c.mu.Lock()
+	converr := c.converrs[len(c.converrs)-1]
+	c.mu.Unlock()
+	c.t.emitFileDone(FileEvent{
+		File:     pdf,
+		WorkerId: c.id,
+		LastPage: -1,
+		Err:      converr,
+	})
+
+	c.setWaiting()
+	c.t.Incr(1)
+	c.endSubmission(c.t.params.ctx.Err() != nil)
+}
+
+func (c *Convertor) setSubmission(s *Submission) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.submission = s
+}
+
+// endSubmission finishes the submission of current file if there is one
+func (c *Convertor) endSubmission(aborted bool) {
+	c.mu.Lock()
+	s := c.submission
+	c.submission = nil
+	c.mu.Unlock()
+
+	if s != nil {
+		s.finish(aborted)
+	}
 }
 
 // receiveEntry is called when an entry is received from the parser. This
@@ -80,44 +251,230 @@ func (c *Convertor) receiveError(err error, page int32) bool {
 //   2. send the entry to the task
 func (c *Convertor) receiveEntry(entry []string) {
 	current, _ := strconv.Atoi(entry[0])
+	last, _ := strconv.Atoi(entry[1])
+
+	now := time.Now()
+	duration := now.Sub(c.pageStartedAt)
+	c.pageStartedAt = now
+
+	c.log(LevelDebug, "page done", Field{"file", c.pdf}, Field{"page", current},
+		Field{"output", entry[2]}, Field{"duration", duration})
+
+	endSpan(c.pageSpan, nil)
+	c.pageSpan = nil
+	if int32(current) < c.last {
+		c.startPageSpan(int32(current) + 1)
+	}
 
 	c.Incr(1)
 	c.SetCurrent(int32(current))
-	c.t.Entries <- append(entry, strconv.Itoa(int(c.id)))
+	c.observe(duration)
+	c.t.records.addPage(c.pdf, int32(current))
+	c.t.Pages.Incr(1)
+	if c.t.kind == KindSingle {
+		c.t.Incr(1)
+	}
+	if s := c.submission; s != nil {
+		s.Incr(1)
+		s.SetCurrent(int32(current))
+		s.observe(duration)
+	}
+	c.trackOutput(entry[2], int32(current))
+	c.t.emitPageDone(PageEvent{
+		File:     c.pdf,
+		WorkerId: c.id,
+		Page:     int32(current),
+		LastPage: int32(last),
+		Output:   entry[2],
+		Duration: duration,
+	})
+
+	entry = append(entry, strconv.Itoa(int(c.id)))
+	switch {
+	case c.submission != nil:
+		c.submission.Entries <- entry
+	case c.segment != nil:
+		c.segment <- entry
+	default:
+		c.t.Entries <- entry
+	}
+}
+
+// onFileStart initializes the progress of a new file (or a part of the file)
+// conversion and notifies the hooks.
+func (c *Convertor) onFileStart(pdf string, first, last int32) {
+	c.setInit(pdf, first, last)
+	c.openSegment()
+
+	c.first, c.last = first, last
+	c.fileErrors = c.errorCount()
+	c.fileBytes, c.overBudget = 0, false
+	c.t.records.addRange(pdf, first, last)
+	c.fileStartedAt = time.Now()
+	c.pageStartedAt = c.fileStartedAt
+	c.log(LevelInfo, "file start", Field{"file", pdf}, Field{"first", first}, Field{"last", last})
+	c.startPageSpan(first)
+	if s := c.submission; s != nil {
+		s.setInit(pdf, first, last)
+		s.timing.start(c.fileStartedAt)
+	}
+
+	c.t.emitFileStart(FileEvent{
+		File:      pdf,
+		WorkerId:  c.id,
+		FirstPage: first,
+		LastPage:  last,
+		Pages:     last - first + 1,
+	})
+}
+
+// onFileDone is called when current file has been converted.
+func (c *Convertor) onFileDone() {
+	c.closeSegment()
+	c.releaseCmd()
+
+	var err error
+	c.mu.Lock()
+	if errs := c.converrs[c.fileErrors:]; len(errs) > 0 {
+		err = errs[0]
+	}
+	c.mu.Unlock()
+	c.log(LevelInfo, "file done", Field{"file", c.pdf}, Field{"pages", c.Finished()},
+		Field{"duration", time.Since(c.fileStartedAt)})
+	c.fileSpan.SetAttributes(Attribute{"pages_converted", c.Finished()})
+	c.endSpans(err)
+
+	c.t.emitFileDone(FileEvent{
+		File:      c.pdf,
+		WorkerId:  c.id,
+		FirstPage: c.first,
+		LastPage:  c.last,
+		Pages:     c.Finished(),
+		Duration:  time.Since(c.fileStartedAt),
+		Err:       err,
+	})
+	c.endSubmission(false)
+}
+
+// trackOutput counts the bytes of a page output, the conversion is stopped
+// once a budget is exceeded. The file of a SingleTask is the task itself.
+func (c *Convertor) trackOutput(output string, page int32) {
+	p := c.params()
+	size := fileSize(output)
+	c.fileBytes += size
+	written := atomic.AddInt64(&c.t.written, size)
+
+	fileBytes := c.fileBytes
+	if c.t.kind == KindSingle {
+		fileBytes = written
+	}
+
+	switch {
+	case p.fileByteBudget > 0 && fileBytes > p.fileByteBudget && !c.overBudget:
+		c.overBudget = true
+		err := newBudgetError(BudgetFile, c.pdf, p.fileByteBudget, fileBytes, false)
+		if c.t.kind == KindSingle {
+			c.abortTask(err, page)
+		} else {
+			c.receiveError(errors.WithStack(err), page)
+			c.stopCmd()
+		}
+
+	case p.byteBudget > 0 && written > p.byteBudget:
+		c.abortTask(newBudgetError(BudgetTask, c.pdf, p.byteBudget, written, false), page)
+	}
+}
+
+// abortTask records the error and cancels the task, only the first call
+// takes effect
+func (c *Convertor) abortTask(err error, page int32) {
+	c.t.abortOnce.Do(func() {
+		c.receiveError(errors.WithStack(err), page)
+		c.t.params.cancel()
+	})
+}
+
+// openSegment queues a new entry stream for the file (or part of the file)
+// the convertor is about to convert, so that the task could deliver its
+// entries in order.
+func (c *Convertor) openSegment() {
+	if c.t.segments == nil {
+		return
+	}
+
+	c.segment = make(chan []string, c.t.params.orderedBuffer)
+	c.t.segments <- c.segment
+}
+
+// closeSegment marks the entry stream of current file as finished.
+func (c *Convertor) closeSegment() {
+	if c.segment != nil {
+		close(c.segment)
+		c.segment = nil
+	}
 }
 
 // current total outputFileName
 var _entryRE = regexp.MustCompile(`(\d+) (\d+) (.+)`)
 
-func (c *Convertor) parseProgress(pipe io.ReadCloser, ch chan<- []string, current int32) {
+// parseProgress parses the progress reported by `cmd` from its stderr `pipe`
+// and sends the entries to `ch`. It owns `cmd` and waits for it, so that the
+// convertor never touches a command being parsed.
+func (c *Convertor) parseProgress(ctx context.Context, cmd *exec.Cmd, pipe io.ReadCloser, ch chan<- []string, pdf string, current, last int32) {
 	scanner := bufio.NewScanner(pipe)
 	defer close(ch)
 
-	for scanner.Scan() {
+	p := c.t.params
+	password := !p.passwordFor(pdf).empty()
+	failed := false
+	stopped := false
+
+	for !stopped && scanner.Scan() {
 		line := scanner.Text()
 
 		// should we continue other worker when error happens?
-		if strings.Contains(line, "Syntax Error") {
-			err := errors.WithStack(NewPDFSyntaxError(line))
-			if ok := c.receiveError(err, current); !ok {
-				return
+		err := classifyPopplerError(line, password, nil)
+		if err != nil {
+			failed = true
+			if ok := c.receiveError(errors.WithStack(err), current); !ok {
+				stopped = true
+				continue
 			}
 		}
 
 		// this is a critical error
 		if strings.HasSuffix(line, "; exiting") {
-			c.receiveError(errors.New(line), current)
-			return
+			if err == nil {
+				c.receiveError(errors.New(line), current)
+			}
+			failed = true
+			stopped = true
+			continue
 		}
 
 		if entry := _entryRE.FindStringSubmatch(line); len(entry) > 3 {
 			pg, _ := strconv.Atoi(entry[1])
+			// errors after this line belong to the next page
 			current = int32(pg)
+			if current < last {
+				current++
+			}
 			ch <- entry[1:]
+			continue
 		}
+
+		c.log(LevelDebug, "stderr", Field{"file", pdf}, Field{"page", current}, Field{"line", line})
+	}
+
+	// the command may block on writing to a pipe nobody reads
+	if stopped {
+		cmd.Process.Kill()
 	}
 
-	c.cmd.Wait()
+	// a process killed by cancellation is reported by the convertor
+	if err := cmd.Wait(); err != nil && !failed && ctx.Err() == nil {
+		c.receiveError(errors.WithStack(err), current)
+	}
 }
 
 // start starts the convertor
@@ -127,16 +484,19 @@ func (c *Convertor) start(pdf string) error {
 	p := c.t.params
 	first, last, _ := p.pageRangeForPart(pdf, c.id)
 
-	pipe, err := c.spwanCmdForPipe(pdf, first, last)
+	c.timing.start(time.Now())
+	c.setState(StateRunning)
+
+	cmd, pipe, err := c.spwanCmdForPipe(pdf, first, last)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	c.Progress.setInit(pdf, first, last)
+	c.onFileStart(pdf, first, last)
 
 	// ch is closed by `parseProgress`
 	ch := make(chan []string, last-first+1)
-	go c.parseProgress(pipe, ch, first)
+	go c.parseProgress(c.cmdCtx, cmd, pipe, ch, pdf, first, last)
 
 	go func() {
 		defer c.onComplete()
@@ -144,10 +504,12 @@ func (c *Convertor) start(pdf string) error {
 			select {
 			case <-p.ctx.Done():
 				c.receiveError(errors.WithStack(p.ctx.Err()), -1)
-				c.aborted = true
+				c.setState(StateCancelled)
 				return
 			case entry, more := <-ch:
 				if !more {
+					c.setCmd(nil)
+					c.onFileDone()
 					return
 				}
 				c.receiveEntry(entry)
@@ -160,63 +522,106 @@ func (c *Convertor) start(pdf string) error {
 
 func (c *Convertor) startAsWorker(provider PdfProvider) {
 	var pdf string
+	var cmd *exec.Cmd
 	var pipe io.ReadCloser
 	var more bool
+
+	// ch is the entry stream of current file, nil means the convertor is
+	// waiting for a file
 	var ch chan []string
 
 	defer c.onComplete()
 
+	c.timing.start(time.Now())
+	c.setState(StateRunning)
 	p := c.t.params
 
-	// set the total number as long as we could get the file count from provider
-	if cnt := provider.Count(); cnt > 0 {
-		c.t.setInit("", 1, int32(cnt))
+	// items are read instead of files if the provider has per-file options
+	var source <-chan string
+	var items <-chan Item
+	if ip, ok := provider.(ItemProvider); ok {
+		items = ip.Items()
+	} else {
+		source = provider.Source()
 	}
 
 	for {
-		if c.cmd == nil {
+		if ch == nil {
+			var item Item
+
 			// accuquire a file for conversion
 			select {
 			case <-p.ctx.Done():
 				c.receiveError(errors.WithStack(p.ctx.Err()), -1)
-				c.aborted = true
+				c.setState(StateCancelled)
+				return
+			case pdf, more = <-source:
+			case item, more = <-items:
+				pdf = item.File
+			}
+			if !more {
 				return
-			case pdf, more = <-provider.Source():
-				if !more {
-					return
-				}
 			}
 
+			if provider.Count() == -1 {
+				c.t.PushTotal(1)
+			}
+
+			c.fileParams = nil
+			c.setSubmission(item.submission)
+			fp, err := p.override(item.Options)
+			if err != nil {
+				c.receiveFileError(pdf, err)
+				continue
+			}
+			c.fileParams = fp
+
 			// page calculation, spwan cmd and pipe
-			first, last, err := p.pageRangeForFile(pdf, c.id)
-			if ok := c.receiveError(err, -1); !ok {
+			// the file is skipped if we could not start the conversion
+			pages, infos, err := fp.fileInfo(pdf)
+			if err != nil {
+				c.receiveFileError(pdf, errors.Wrap(err, "failed to get pages count "))
+				continue
+			}
+
+			first, last, err := fp.pageRangeForFile(pages)
+			if err != nil {
+				c.receiveFileError(pdf, err)
+				continue
+			}
+
+			if err := fp.checkBudget(pdf, pages, infos, c.t.BytesWritten()); err != nil {
+				c.receiveFileError(pdf, err)
 				continue
 			}
 
-			pipe, err = c.spwanCmdForPipe(pdf, first, last)
-			if ok := c.receiveError(err, -1); !ok {
+			cmd, pipe, err = c.spwanCmdForPipe(pdf, first, last)
+			if err != nil {
+				c.receiveFileError(pdf, err)
 				continue
 			}
 
 			// initialize new file conversion progress
-			c.setInit(pdf, first, last)
-			if provider.Count() == -1 {
-				c.t.PushTotal(1)
+			c.onFileStart(pdf, first, last)
+			if !p.preflight {
+				c.t.Pages.PushTotal(last - first + 1)
 			}
 
 			ch = make(chan []string, last-first+1)
-			go c.parseProgress(pipe, ch, first)
+			go c.parseProgress(c.cmdCtx, cmd, pipe, ch, pdf, first, last)
 		}
 
 		select {
 		case <-p.ctx.Done():
-			c.receiveError(errors.WithStack(p.ctx.Err()), c.current)
-			c.aborted = true
+			c.receiveError(errors.WithStack(p.ctx.Err()), c.Current())
+			c.setState(StateCancelled)
 			return
 		case entry, more := <-ch:
 			// no more entry means conversion has finised for that file
 			if !more {
-				c.cmd = nil
+				ch = nil
+				c.setCmd(nil)
+				c.onFileDone()
 				c.setWaiting()
 				c.t.Incr(1)
 			} else {
@@ -227,10 +632,38 @@ func (c *Convertor) startAsWorker(provider PdfProvider) {
 }
 
 func (c *Convertor) onComplete() {
+	if !c.State().Terminal() {
+		c.setState(StateDraining)
+	}
+
+	c.timing.stop(time.Now())
+	c.t.metrics.workers(-1)
+	if c.fileSpan != nil {
+		c.endSpans(c.Error())
+	}
+	c.closeSegment()
+	c.releaseCmd()
+	c.endSubmission(c.State() == StateCancelled)
+
+	if !c.State().Terminal() {
+		if c.errorCount() > 0 {
+			c.setState(StateFailed)
+		} else {
+			c.setState(StateCompleted)
+		}
+	}
 	close(c.done)
 	c.t.wg.Done()
 }
 
+// setState moves the convertor to state `s`, an invalid transition is a bug
+// and is logged
+func (c *Convertor) setState(s State) {
+	if err := c.transition(s); err != nil {
+		c.log(LevelWarn, "state transition", Field{"error", err})
+	}
+}
+
 // Completed reports whether the convertor is in completed state
 func (c *Convertor) Completed() bool {
 	select {
@@ -241,6 +674,7 @@ func (c *Convertor) Completed() bool {
 	}
 }
 
+// Aborted reports whether the convertor was stopped by cancellation
 func (c *Convertor) Aborted() bool {
-	return false
+	return c.State() == StateCancelled
 }
@@ -0,0 +1,181 @@
+package pico
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// waitOrFail waits for the task, the test fails if it takes longer than `d`
+func waitOrFail(t *testing.T, task *Task, d time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		task.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatalf("task %d did not finish in %s", task.Id(), d)
+	}
+}
+
+// observe reads everything observable of the task until it completes
+func observe(task *Task, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for !task.Completed() {
+		task.Filename()
+		task.Stats()
+		task.Aborted()
+		task.Pages.Finished()
+		for _, c := range task.Convertors {
+			c.Filename()
+			c.Current()
+			c.Stats()
+			c.Error()
+			c.Errors()
+			c.Aborted()
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConcurrentObservation(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+
+	var files []string
+	for i := 0; i < 6; i++ {
+		files = append(files, fakePDF(t, dir, fmt.Sprintf("%d.pdf", i), 5, 0.01,
+			"Stderr: 3 Syntax Error (1): Illegal character"))
+	}
+
+	task, err := ConvertFiles(files,
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+		WithJob(3),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+
+	sub := task.Subscribe(PolicyDropOldest, 4)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go observe(&task.Task, &wg)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range sub.C {
+		}
+	}()
+
+	waitOrFail(t, &task.Task, 10*time.Second)
+	wg.Wait()
+
+	assert.Len(t, task.Errors(), 6)
+	assert.EqualValues(t, 30, task.Pages.Finished())
+	assert.False(t, task.Aborted())
+}
+
+func TestCancellationStress(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+
+	var files []string
+	for i := 0; i < 4; i++ {
+		files = append(files, fakePDF(t, dir, fmt.Sprintf("%d.pdf", i), 8, 0.01))
+	}
+
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		var task *Task
+		if i%2 == 0 {
+			single, err := Convert(files[0],
+				WithPopplerPath(poppler),
+				WithOutputFolder(t.TempDir()),
+				WithContext(ctx),
+				WithJob(4),
+			)
+			assert.NoError(t, err, "conversion task initialization should not failed")
+			task = &single.Task
+		} else {
+			batch, err := ConvertFiles(files,
+				WithPopplerPath(poppler),
+				WithOutputFolder(t.TempDir()),
+				WithContext(ctx),
+				WithJob(2),
+			)
+			assert.NoError(t, err, "conversion task initialization should not failed")
+			task = &batch.Task
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go observe(task, &wg)
+
+		time.Sleep(time.Duration(i*5) * time.Millisecond)
+		cancel()
+
+		waitOrFail(t, task, 10*time.Second)
+		wg.Wait()
+
+		if task.Aborted() {
+			assert.True(t, errors.Is(task.Error(), context.Canceled))
+		}
+		for _, fr := range task.Report().Files {
+			for _, page := range fr.Succeeded {
+				assert.NotContains(t, fr.Skipped, page)
+			}
+		}
+	}
+}
+
+func TestStrictModeStress(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+
+	var broken, ok []string
+	for i := 0; i < 4; i++ {
+		broken = append(broken, fakePDF(t, dir, fmt.Sprintf("broken-%d.pdf", i), 6, 0,
+			"Stderr: 2 Syntax Error (1): Illegal character",
+			"Stderr: 4 Syntax Error (2): Illegal character"))
+		ok = append(ok, fakePDF(t, dir, fmt.Sprintf("ok-%d.pdf", i), 3, 0))
+	}
+
+	task, err := ConvertFiles(append(broken, ok...),
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+		WithStrict(),
+		WithJob(4),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go observe(&task.Task, &wg)
+
+	waitOrFail(t, &task.Task, 10*time.Second)
+	wg.Wait()
+
+	report := task.Report()
+	for _, pdf := range broken {
+		// the conversion stops at the first syntax error
+		fr := report.Files[pdf]
+		assert.Equal(t, []int32{1}, fr.Succeeded, pdf)
+		assert.Equal(t, []int32{2, 3, 4, 5, 6}, fr.Skipped, pdf)
+		assert.Len(t, fr.Errors[2], 1, pdf)
+	}
+	for _, pdf := range ok {
+		assert.False(t, report.Files[pdf].Failed(), pdf)
+		assert.Equal(t, []int32{1, 2, 3}, report.Files[pdf].Succeeded, pdf)
+	}
+}
@@ -2,6 +2,7 @@ package pico
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -29,11 +30,36 @@ type ConversionError struct {
 	err      error
 }
 
+// File returns the pdf file failed to convert
+func (e *ConversionError) File() string {
+	return e.pdf
+}
+
+// Page returns the page failed to convert, -1 means the error is not
+// related to a specific page
+func (e *ConversionError) Page() int32 {
+	return e.page
+}
+
+// WorkerId returns the id of the convertor reported the error, -1 means the
+// error is not reported by a convertor
+func (e *ConversionError) WorkerId() int32 {
+	return e.workerId
+}
+
 func (e *ConversionError) Cause() error {
 	return e.err
 }
 
+func (e *ConversionError) Unwrap() error {
+	return e.err
+}
+
 func (e *ConversionError) Error() string {
+	if e.pdf == "" && e.workerId < 0 {
+		return fmt.Sprintf("failed to provide files: %s", e.err)
+	}
+
 	worker, page := "", ""
 	if e.workerId >= 0 {
 		worker = fmt.Sprintf(" by worker#%02d", e.workerId)
@@ -46,8 +72,62 @@ func (e *ConversionError) Error() string {
 	return fmt.Sprintf("failed to convert %s%s%s: %s", e.pdf, worker, page, e.err)
 }
 
+// MultiError aggregates multiple errors, `errors.Is` and `errors.As` match
+// any of the errors.
+type MultiError struct {
+	Errors []error
+}
+
+func newMultiError(errs ...*ConversionError) *MultiError {
+	e := &MultiError{}
+	for _, err := range errs {
+		e.Errors = append(e.Errors, err)
+	}
+	return e
+}
+
+func (e *MultiError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors occurred: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+func (e *MultiError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *MultiError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns all the errors, which is understood by `errors.Is` and
+// `errors.As` since go 1.20
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
 var ErrProviderClosed = errors.New("provider is closed")
 
+// ErrSubmissionsClosed is returned by `BatchTask.Submit` if the task does
+// not accept files
+var ErrSubmissionsClosed = errors.New("submissions are closed")
+
 func NewPerPageTimeoutError(page string) *PerPageTimeoutError {
 	return &PerPageTimeoutError{
 		msg: fmt.Sprintf("processing page %s timeout", page),
@@ -100,3 +180,213 @@ func NewPDFSyntaxError(line string) *PDFSyntaxError {
 func (e *PDFSyntaxError) Error() string {
 	return e.msg
 }
+
+// popplerError is the common part of errors classified from the stderr output
+// of poppler utilities, `line` is the original output and `cause` is usually
+// the exit error of the process.
+type popplerError struct {
+	line  string
+	cause error
+}
+
+func (e *popplerError) Error() string {
+	return fmt.Sprintf("poppler: %s", e.line)
+}
+
+// Line returns the poppler output the error is classified from
+func (e *popplerError) Line() string {
+	return e.line
+}
+
+func (e *popplerError) Unwrap() error {
+	return e.cause
+}
+
+// IncorrectPasswordError means the given user or owner password is wrong
+type IncorrectPasswordError struct {
+	popplerError
+}
+
+// EncryptedError means the file is encrypted but no password is given
+type EncryptedError struct {
+	popplerError
+}
+
+// isPasswordError reports whether the error means the password is wrong or
+// missing, thus another password may open the file
+func isPasswordError(err error) bool {
+	var incorrectPasswordError *IncorrectPasswordError
+	var encryptedError *EncryptedError
+	return errors.As(err, &incorrectPasswordError) || errors.As(err, &encryptedError)
+}
+
+// LockedError means none of the candidate passwords opens the file, it wraps
+// the error of the last attempt.
+type LockedError struct {
+	file  string
+	tried int
+	cause error
+}
+
+func newLockedError(file string, tried int, cause error) *LockedError {
+	return &LockedError{file: file, tried: tried, cause: cause}
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s is locked, none of the %d password(s) works: %s", e.file, e.tried, e.cause)
+}
+
+// File returns the locked file
+func (e *LockedError) File() string {
+	return e.file
+}
+
+// Tried returns the number of passwords tried
+func (e *LockedError) Tried() int {
+	return e.tried
+}
+
+func (e *LockedError) Unwrap() error {
+	return e.cause
+}
+
+// PermissionError means the operation is not allowed by the document
+type PermissionError struct {
+	popplerError
+}
+
+// DamagedFileError means the file is corrupted, e.g. the xref table is
+// broken or the file is not a PDF at all. A damaged file that poppler reports
+// as a syntax error also matches *PDFSyntaxError with `errors.As`.
+type DamagedFileError struct {
+	popplerError
+
+	syntax *PDFSyntaxError
+}
+
+func (e *DamagedFileError) As(target interface{}) bool {
+	if t, ok := target.(**PDFSyntaxError); ok && e.syntax != nil {
+		*t = e.syntax
+		return true
+	}
+	return false
+}
+
+// UnsupportedFeatureError means the file uses a feature poppler does not
+// implement
+type UnsupportedFeatureError struct {
+	popplerError
+}
+
+// OutOfMemoryError means poppler fails to allocate memory
+type OutOfMemoryError struct {
+	popplerError
+}
+
+// IOError means poppler fails to read the file or write the output
+type IOError struct {
+	popplerError
+}
+
+func newIOError(cause error) *IOError {
+	return &IOError{popplerError{line: cause.Error(), cause: cause}}
+}
+
+var _damagedFilePatterns = []string{
+	"xref",
+	"trailer",
+	"May not be a PDF file",
+	"Couldn't find trailer dictionary",
+	"damaged",
+	"Invalid XRef",
+}
+
+var _unsupportedPatterns = []string{
+	"Unimplemented",
+	"not implemented",
+	"Unsupported",
+	"not supported",
+}
+
+var _outOfMemoryPatterns = []string{
+	"Out of memory",
+	"out of memory",
+	"Bogus memory allocation size",
+	"bad_alloc",
+}
+
+// unrecoverable reports whether the line is an error poppler does not
+// recover from, warnings like "May not be a PDF file (continuing anyway)" or
+// "try to reconstruct" are not
+func unrecoverable(line string) bool {
+	return strings.HasPrefix(line, "Syntax Error") || strings.HasPrefix(line, "Error") ||
+		strings.HasSuffix(line, "; exiting") || strings.Contains(line, "Couldn't read xref table")
+}
+
+func containsAny(line string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.Contains(line, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyPopplerError classifies a line of poppler stderr output into a typed
+// error, nil is returned if the line is not an error. `password` tells whether
+// any password is given, which separates a wrong password from a missing one.
+func classifyPopplerError(line string, password bool, cause error) error {
+	base := popplerError{line: line, cause: cause}
+
+	switch {
+	case strings.Contains(line, "Incorrect password"):
+		if password {
+			return &IncorrectPasswordError{base}
+		}
+		return &EncryptedError{base}
+
+	case strings.HasPrefix(line, "Permission Error"):
+		return &PermissionError{base}
+
+	case containsAny(line, _outOfMemoryPatterns):
+		return &OutOfMemoryError{base}
+
+	case strings.HasPrefix(line, "I/O Error"):
+		return &IOError{base}
+
+	case containsAny(line, _unsupportedPatterns):
+		return &UnsupportedFeatureError{base}
+
+	case containsAny(line, _damagedFilePatterns) && unrecoverable(line):
+		e := &DamagedFileError{popplerError: base}
+		if strings.Contains(line, "Syntax Error") {
+			e.syntax = NewPDFSyntaxError(line)
+		}
+		return e
+
+	case strings.Contains(line, "Syntax Error"):
+		return NewPDFSyntaxError(line)
+	}
+
+	return nil
+}
+
+// classifyPopplerOutput finds the first error in the output of a poppler
+// utility, errors other than syntax errors take precedence since poppler
+// usually recovers from syntax errors.
+func classifyPopplerOutput(output string, password bool, cause error) error {
+	var syntaxError error
+	for _, line := range strings.Split(output, "\n") {
+		err := classifyPopplerError(strings.TrimSpace(line), password, cause)
+		switch err.(type) {
+		case nil:
+		case *PDFSyntaxError:
+			if syntaxError == nil {
+				syntaxError = err
+			}
+		default:
+			return err
+		}
+	}
+	return syntaxError
+}
@@ -0,0 +1,149 @@
+package pico
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyPopplerError(t *testing.T) {
+	kases := []struct {
+		line     string
+		password bool
+		expect   interface{}
+	}{
+		{"Command Line Error: Incorrect password", true, &IncorrectPasswordError{}},
+		{"Command Line Error: Incorrect password", false, &EncryptedError{}},
+		{"Permission Error: Copying of text from this document is not allowed.", false, &PermissionError{}},
+		{"Syntax Error: Couldn't read xref table", false, &DamagedFileError{}},
+		{"Error: May not be a PDF file", false, &DamagedFileError{}},
+		{"Internal Error: xref table is damaged; exiting", false, &DamagedFileError{}},
+		{"Syntax Warning: May not be a PDF file (continuing anyway)", false, nil},
+		{"Internal Error: xref num 12 not found but needed, try to reconstruct", false, nil},
+		{"Syntax Warning: Invalid XRef entry 3", false, nil},
+		{"Syntax Error: Unimplemented feature: JBIG2 refinement", false, &UnsupportedFeatureError{}},
+		{"Internal Error: Bogus memory allocation size", false, &OutOfMemoryError{}},
+		{"I/O Error: Couldn't open file 'a.pdf': No such file or directory.", false, &IOError{}},
+		{"Syntax Error (1234): Illegal character '>'", false, &PDFSyntaxError{}},
+		{"1 14 output-01.ppm", false, nil},
+	}
+
+	for _, kase := range kases {
+		err := classifyPopplerError(kase.line, kase.password, nil)
+		if kase.expect == nil {
+			assert.NoError(t, err, kase.line)
+			continue
+		}
+		assert.IsType(t, kase.expect, err, kase.line)
+	}
+
+	// damaged file reported as syntax error is also a syntax error
+	var syntaxError *PDFSyntaxError
+	err := errors.WithStack(classifyPopplerError("Syntax Error: Couldn't read xref table", false, nil))
+	assert.ErrorAs(t, err, &syntaxError)
+}
+
+func TestPopplerErrorsFromFakePoppler(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+	locked := fakePDF(t, dir, "locked.pdf", 3, 0, "Password: open-sesame")
+
+	var encryptedError *EncryptedError
+	_, err := GetInfo(locked, WithPopplerPath(poppler))
+	assert.ErrorAs(t, err, &encryptedError)
+
+	var incorrectPasswordError *IncorrectPasswordError
+	_, err = GetInfo(locked, WithPopplerPath(poppler), WithUserPw("wrong"))
+	assert.ErrorAs(t, err, &incorrectPasswordError)
+
+	_, err = GetInfo(locked, WithPopplerPath(poppler), WithUserPw("open-sesame"))
+	assert.NoError(t, err)
+
+	var ioError *IOError
+	_, err = GetInfo(fmt.Sprintf("%s/missing.pdf", dir), WithPopplerPath(poppler))
+	assert.ErrorAs(t, err, &ioError)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	// errors during conversion are classified too
+	task, err := Convert(fakePDF(t, dir, "damaged.pdf", 3, 0,
+		"Stderr: 2 Syntax Error: Couldn't read xref table"),
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+	task.Wait()
+
+	var damagedFileError *DamagedFileError
+	assert.ErrorAs(t, task.Error(), &damagedFileError)
+
+	// warnings poppler recovers from are not errors, even in strict mode
+	task, err = Convert(fakePDF(t, dir, "recovered.pdf", 3, 0,
+		"Stderr: 1 Syntax Warning: May not be a PDF file (continuing anyway)",
+		"Stderr: 2 Internal Error: xref num 12 not found but needed, try to reconstruct"),
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+		WithStrict(),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+	task.Wait()
+
+	assert.NoError(t, task.Error())
+	assert.EqualValues(t, 3, task.Pages.Finished())
+}
+
+func TestPopplerErrorsFromFixtures(t *testing.T) {
+	var encryptedError *EncryptedError
+	_, err := GetInfo(fmt.Sprintf("%s%s", folder, "test_locked_user_only.pdf"))
+	assert.ErrorAs(t, err, &encryptedError)
+
+	var incorrectPasswordError *IncorrectPasswordError
+	_, err = GetInfo(fmt.Sprintf("%s%s", folder, "test_locked_user_only.pdf"), WithUserPw("wrong"))
+	assert.ErrorAs(t, err, &incorrectPasswordError)
+
+	var damagedFileError *DamagedFileError
+	_, err = GetInfo(fmt.Sprintf("%s%s", folder, "test_corrupted.pdf"))
+	assert.ErrorAs(t, err, &damagedFileError)
+}
+
+func TestTaskReport(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+
+	ok := fakePDF(t, dir, "ok.pdf", 3, 0)
+	broken := fakePDF(t, dir, "broken.pdf", 4, 0, "Stderr: 2 Syntax Error (42): Illegal character")
+	missing := fmt.Sprintf("%s/missing.pdf", dir)
+
+	task, err := ConvertFiles([]string{ok, broken, missing},
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+	task.Wait()
+
+	var multiError *MultiError
+	var syntaxError *PDFSyntaxError
+	var ioError *IOError
+	err = task.Error()
+	assert.ErrorAs(t, err, &multiError)
+	assert.Len(t, multiError.Errors, 2)
+	assert.ErrorAs(t, err, &syntaxError)
+	assert.ErrorAs(t, err, &ioError)
+
+	report := task.Report()
+	assert.Len(t, report.Files, 3)
+
+	assert.False(t, report.Files[ok].Failed())
+	assert.Equal(t, []int32{1, 2, 3}, report.Files[ok].Succeeded)
+
+	if errs := report.Files[broken].Errors[2]; assert.Len(t, errs, 1) {
+		assert.Equal(t, broken, errs[0].File())
+		assert.EqualValues(t, 2, errs[0].Page())
+		assert.True(t, errs[0].WorkerId() >= 0)
+	}
+
+	assert.Len(t, report.Files[missing].Errors[-1], 1)
+	assert.Empty(t, report.Files[missing].Succeeded)
+}
@@ -6,7 +6,7 @@ require github.com/pkg/errors v0.9.1
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/mattn/go-runewidth v0.0.14 // indirect
+	github.com/mattn/go-runewidth v0.0.14
 	github.com/rivo/uniseg v0.4.4 // indirect
 	github.com/stretchr/testify v1.7.2
 	github.com/vbauerster/mpb/v7 v7.4.2
@@ -0,0 +1,169 @@
+package pico
+
+import (
+	"time"
+)
+
+// FileEvent describes the conversion of a file by a convertor. For a
+// SingleTask, every convertor converts only a part of the file, thus
+// `FirstPage` and `LastPage` are the page range of that part.
+type FileEvent struct {
+	File     string
+	WorkerId int32
+
+	FirstPage int32
+	LastPage  int32
+
+	// Pages is the number of pages to convert when the file starts, and the
+	// number of pages actually converted when the file is done
+	Pages int32
+
+	// Duration is zero when the file starts
+	Duration time.Duration
+
+	// Err is the first error occurred during the conversion of the file
+	Err error
+}
+
+// PageEvent describes a converted page
+type PageEvent struct {
+	File     string
+	WorkerId int32
+
+	Page     int32
+	LastPage int32
+
+	// Output is the filename of the resulting image
+	Output string
+
+	// Duration is the time spent on rendering the page
+	Duration time.Duration
+}
+
+// TaskEvent describes a completed task
+type TaskEvent struct {
+	// Pages is the number of pages converted
+	Pages int32
+
+	Duration time.Duration
+	Errors   []*ConversionError
+}
+
+// hooks holds the callbacks registered by `WithOnXXX` options. Hooks are
+// called synchronously by the convertors, which means they may be called
+// concurrently and a slow hook slows down the conversion.
+type hooks struct {
+	onFileStart []func(FileEvent)
+	onPageDone  []func(PageEvent)
+	onFileDone  []func(FileEvent)
+	onError     []func(*ConversionError)
+	onTaskDone  []func(TaskEvent)
+}
+
+func (h *hooks) fileStart(e FileEvent) {
+	for _, fn := range h.onFileStart {
+		fn(e)
+	}
+}
+
+func (h *hooks) pageDone(e PageEvent) {
+	for _, fn := range h.onPageDone {
+		fn(e)
+	}
+}
+
+func (h *hooks) fileDone(e FileEvent) {
+	for _, fn := range h.onFileDone {
+		fn(e)
+	}
+}
+
+func (h *hooks) error(err *ConversionError) {
+	for _, fn := range h.onError {
+		fn(err)
+	}
+}
+
+func (h *hooks) taskDone(e TaskEvent) {
+	for _, fn := range h.onTaskDone {
+		fn(e)
+	}
+}
+
+// emitFileStart notifies the hooks and subscribers that a file starts
+func (t *Task) emitFileStart(e FileEvent) {
+	t.params.hooks.fileStart(e)
+	t.broker.publish(Event{Kind: EventFileStart, Time: time.Now(), File: &e})
+}
+
+func (t *Task) emitPageDone(e PageEvent) {
+	t.params.hooks.pageDone(e)
+	t.metrics.pageDone(e)
+	t.broker.publish(Event{Kind: EventPageDone, Time: time.Now(), Page: &e})
+}
+
+func (t *Task) emitFileDone(e FileEvent) {
+	t.params.hooks.fileDone(e)
+	t.metrics.fileDone(e)
+	t.broker.publish(Event{Kind: EventFileDone, Time: time.Now(), File: &e})
+}
+
+func (t *Task) emitError(err *ConversionError) {
+	t.params.hooks.error(err)
+	t.metrics.error(err)
+	t.broker.publish(Event{Kind: EventError, Time: time.Now(), Error: err})
+}
+
+// emitTaskDone notifies the hooks and subscribers that the task is done, all
+// the subscriptions are closed afterwards.
+func (t *Task) emitTaskDone(e TaskEvent) {
+	t.params.hooks.taskDone(e)
+	t.metrics.taskDone()
+	t.broker.publish(Event{Kind: EventTaskDone, Time: time.Now(), Task: &e})
+	t.broker.close()
+}
+
+// WithOnFileStart registers a hook called when a convertor starts to convert
+// a file (or a part of the file)
+func WithOnFileStart(fn func(FileEvent)) CallOption {
+	return func(p *Parameters, command []string) []string {
+		p.hooks.onFileStart = append(p.hooks.onFileStart, fn)
+		return command
+	}
+}
+
+// WithOnPageDone registers a hook called when a page is converted
+func WithOnPageDone(fn func(PageEvent)) CallOption {
+	return func(p *Parameters, command []string) []string {
+		p.hooks.onPageDone = append(p.hooks.onPageDone, fn)
+		return command
+	}
+}
+
+// WithOnFileDone registers a hook called when a convertor finishes a file
+// (or a part of the file). It is not called for the file being converted
+// when the task is cancelled. A file failed to start, e.g. it is missing or
+// locked, is reported with the error but without a start.
+func WithOnFileDone(fn func(FileEvent)) CallOption {
+	return func(p *Parameters, command []string) []string {
+		p.hooks.onFileDone = append(p.hooks.onFileDone, fn)
+		return command
+	}
+}
+
+// WithOnError registers a hook called when an error occurs
+func WithOnError(fn func(*ConversionError)) CallOption {
+	return func(p *Parameters, command []string) []string {
+		p.hooks.onError = append(p.hooks.onError, fn)
+		return command
+	}
+}
+
+// WithOnTaskDone registers a hook called when all the convertors of the task
+// are completed, right before `Wait()` returns
+func WithOnTaskDone(fn func(TaskEvent)) CallOption {
+	return func(p *Parameters, command []string) []string {
+		p.hooks.onTaskDone = append(p.hooks.onTaskDone, fn)
+		return command
+	}
+}
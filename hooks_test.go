@@ -0,0 +1,66 @@
+package pico
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHooks(t *testing.T) {
+	cases := []struct {
+		kind  string
+		pages []int
+	}{
+		{"SingleTask", []int{6}},
+		{"BatchTask", []int{3, 5}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.kind, func(t *testing.T) {
+			var mu sync.Mutex
+			started, done, pages := map[string]int32{}, map[string]int32{}, 0
+			var taskEvent *TaskEvent
+
+			files := fakeFiles(t, 0, c.pages...)
+			task, err := converts[c.kind](files, fakeOptions(t,
+				WithJob(2),
+				WithOnFileStart(func(e FileEvent) {
+					mu.Lock()
+					defer mu.Unlock()
+					started[filepath.Base(e.File)] += e.Pages
+				}),
+				WithOnPageDone(func(e PageEvent) {
+					mu.Lock()
+					defer mu.Unlock()
+					pages++
+				}),
+				WithOnFileDone(func(e FileEvent) {
+					mu.Lock()
+					defer mu.Unlock()
+					done[filepath.Base(e.File)] += e.Pages
+					assert.NoError(t, e.Err)
+				}),
+				WithOnTaskDone(func(e TaskEvent) {
+					taskEvent = &e
+				}),
+			)...)
+			assert.NoError(t, err, "conversion task initialization should not failed")
+			task.Wait()
+
+			expect, total := map[string]int32{}, 0
+			for i, pdf := range files {
+				expect[filepath.Base(pdf)] = int32(c.pages[i])
+				total += c.pages[i]
+			}
+			assert.Equal(t, expect, started)
+			assert.Equal(t, expect, done)
+			assert.Equal(t, total, pages)
+			if assert.NotNil(t, taskEvent) {
+				assert.Empty(t, taskEvent.Errors)
+				assert.EqualValues(t, total, taskEvent.Pages)
+			}
+		})
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"fmt"
+	"math"
 	"os"
 	"strconv"
 	"strings"
@@ -23,56 +23,104 @@ func defaultGetInfoCallArguments() *Parameters {
 }
 
 func GetInfo(pdf string, options ...CallOption) (map[string]string, error) {
+	infos, _, err := getInfo(pdf, options...)
+	return infos, err
+}
+
+// getInfo acts like GetInfo and also returns the password that opened the
+// pdf, the candidate passwords are tried in turn until one of them works.
+func getInfo(pdf string, options ...CallOption) (map[string]string, Password, error) {
 	p := defaultGetInfoCallArguments()
 
 	for _, option := range options {
 		option(p, nil)
 	}
+	p.setupLogger()
 
-	if _, err := os.Stat(pdf); errors.Is(err, os.ErrNotExist) {
-		return nil, errors.WithStack(err)
+	if _, err := os.Stat(pdf); err != nil {
+		return nil, Password{}, errors.WithStack(newIOError(err))
 	}
 
-	command := []string{
-		getCommandPath("pdfinfo", p.popplerPath),
-		pdf,
+	var infos map[string]string
+	pw, err := p.tryPasswords(pdf, func(pw Password) (err error) {
+		infos, err = p.runPdfinfo(pdf, pw)
+		return err
+	})
+	return infos, pw, err
+}
+
+// tryPasswords runs `open` with the candidate passwords of the pdf in turn
+// until one of them works, the password is returned.
+func (p *Parameters) tryPasswords(pdf string, open func(Password) error) (Password, error) {
+	var err error
+	candidates := p.passwordCandidates(pdf)
+	for i, pw := range candidates {
+		err = open(pw)
+		if err == nil {
+			if i > 0 {
+				p.logger.Log(LevelInfo, "password accepted", Field{"file", pdf}, Field{"attempt", i + 1})
+			}
+			return pw, nil
+		}
+
+		if !isPasswordError(err) {
+			return Password{}, err
+		}
+		if i < len(candidates)-1 {
+			p.logger.Log(LevelWarn, "password rejected, trying next candidate", Field{"file", pdf},
+				Field{"attempt", i + 1}, Field{"candidates", len(candidates)})
+		}
 	}
 
-	if p.userPw != "" {
-		command = append(command, "-upw", p.userPw)
+	if p.passwordProvider != nil {
+		err = errors.WithStack(newLockedError(pdf, len(candidates), err))
 	}
+	return Password{}, err
+}
 
-	if p.ownerPw != "" {
-		command = append(command, "-opw", p.ownerPw)
+// runPdfinfo runs pdfinfo on the pdf with the password
+func (p *Parameters) runPdfinfo(pdf string, pw Password) (map[string]string, error) {
+	command := []string{
+		getCommandPath("pdfinfo", p.popplerPath),
+		pdf,
 	}
+	command = append(command, pw.args()...)
 
 	if p.rawDates {
 		command = append(command, "-rawdates")
 	}
 
-	if p.timeout > 0 {
-		p.ctx, p.cancel = context.WithTimeout(p.ctx, p.timeout)
-		defer p.cancel()
+	// pdfinfo clamps the last page to the page count
+	if p.pageSizes {
+		first, last := p.pageRange(math.MaxInt32)
+		command = append(command, "-f", strconv.Itoa(int(first)), "-l", strconv.Itoa(int(last)))
 	}
 
-	cmd := buildCmd(p.ctx, p.popplerPath, command)
-	if p.verbose {
-		fmt.Println("Call using ", cmd.String())
+	ctx := p.ctx
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
 	}
 
+	cmd := buildCmd(ctx, p.popplerPath, command)
+	p.logger.Log(LevelDebug, "spawn command", Field{"file", pdf}, Field{"command", command})
+
 	buf, err := cmd.CombinedOutput()
 	if err != nil {
+		p.logger.Log(LevelError, "pdfinfo failed", Field{"file", pdf}, Field{"error", err},
+			Field{"output", strings.TrimSpace(string(buf))})
+		if perr := classifyPopplerOutput(string(buf), !pw.empty(), err); perr != nil {
+			return nil, errors.WithStack(perr)
+		}
 		return nil, errors.WithStack(err)
 	}
-
 	infos := map[string]string{}
 	scanner := bufio.NewScanner(bytes.NewReader(buf))
 
 	for scanner.Scan() {
 		if strings.HasPrefix(scanner.Text(), "I/O Error:") {
-			if p.verbose {
-				fmt.Println("Error:", scanner.Text())
-			}
+			p.logger.Log(LevelWarn, "stderr", Field{"file", pdf}, Field{"line", scanner.Text()})
 			continue
 		}
 		pairs := strings.Split(scanner.Text(), ":")
@@ -84,15 +132,27 @@ func GetInfo(pdf string, options ...CallOption) (map[string]string, error) {
 }
 
 func GetPagesCount(pdfPath string, options ...CallOption) (int, error) {
-	infos, err := GetInfo(pdfPath, options...)
+	pages, _, err := getPagesCount(pdfPath, options...)
+	return pages, err
+}
+
+// getPagesCount acts like GetPagesCount and also returns the password that
+// opened the pdf
+func getPagesCount(pdfPath string, options ...CallOption) (int, Password, error) {
+	infos, pw, err := getInfo(pdfPath, options...)
 	if err != nil {
-		return 0, err
+		return 0, pw, err
 	}
 
+	n, err := pagesOf(infos)
+	return n, pw, err
+}
+
+// pagesOf returns the page count reported by pdfinfo
+func pagesOf(infos map[string]string) (int, error) {
 	pages, ok := infos["Pages"]
 	if !ok {
 		return 0, errors.New("missing 'Pages' entry")
 	}
-
 	return strconv.Atoi(pages)
 }
@@ -0,0 +1,120 @@
+package pico
+
+import (
+	"sync"
+)
+
+// Item is a file with its own options, which are applied on top of the
+// options of the task. Options that configure the task as a whole, like the
+// context, timeout, hooks, logger, worker count and byte budget of the task,
+// are ignored.
+type Item struct {
+	File    string
+	Options []CallOption
+
+	// submission is the handle of a file submitted by `BatchTask.Submit`
+	submission *Submission
+}
+
+// ItemProvider provides files with their own options, convertors read
+// `Items()` rather than `Source()` from it. Both channels are fed by the same
+// items, thus an item is read from either of them.
+type ItemProvider interface {
+	PdfProvider
+	Items() <-chan Item
+}
+
+type ChanItemProvider struct {
+	providerState
+
+	items chan Item
+
+	once   sync.Once
+	source chan string
+}
+
+type SliceItemProvider struct {
+	ChanItemProvider
+
+	len int
+}
+
+func (p *ChanItemProvider) Items() <-chan Item {
+	return p.items
+}
+
+// Source provides the files of the items, their options are dropped
+func (p *ChanItemProvider) Source() <-chan string {
+	p.once.Do(func() {
+		p.source = make(chan string)
+		go func() {
+			defer close(p.source)
+			for item := range p.items {
+				p.source <- item.File
+			}
+		}()
+	})
+	return p.source
+}
+
+func (p *ChanItemProvider) Count() int {
+	return -1
+}
+
+func (p *SliceItemProvider) Count() int {
+	return p.len
+}
+
+func FromItems(items []Item) ItemProvider {
+	ch := make(chan Item, len(items))
+	defer close(ch)
+	for _, item := range items {
+		ch <- item
+	}
+	return &SliceItemProvider{
+		ChanItemProvider{items: ch},
+		len(items),
+	}
+}
+
+func FromItemChan(ch chan Item) ItemProvider {
+	return &ChanItemProvider{items: ch}
+}
+
+// override returns the parameters of a file whose own options are applied on
+// top of p, p itself is returned if there is no option
+func (p *Parameters) override(options []CallOption) (*Parameters, error) {
+	if len(options) == 0 {
+		return p, nil
+	}
+
+	q := *p
+	q.argErrors = nil
+	q.binary = "pdftoppm"
+	q.jpegOpt = nil
+	for k, v := range p.jpegOpt {
+		q.setJPEGOpt(k, v)
+	}
+
+	args := append([]string{}, p.optionArgs...)
+	for _, option := range options {
+		args = option(&q, args)
+	}
+
+	// the task wide settings are kept
+	q.ctx, q.cancel, q.timeout = p.ctx, p.cancel, p.timeout
+	q.logger, q.tracer, q.metrics, q.hooks = p.logger, p.tracer, p.metrics, p.hooks
+	q.job, q.orderedBuffer, q.preflight = p.job, p.orderedBuffer, p.preflight
+	q.byteBudget, q.freeSpaceCheck = p.byteBudget, p.freeSpaceCheck
+	q.passwords, q.pageCounts, q.pageInfos = p.passwords, p.pageCounts, p.pageInfos
+	q.options = append(append([]CallOption{}, p.options...), options...)
+
+	base, err := q.buildBaseCommand(p)
+	if err != nil {
+		return nil, err
+	}
+	q.optionArgs = args
+	q.baseCommand = append(args, base...)
+
+	return &q, nil
+}
@@ -0,0 +1,84 @@
+package pico
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItemOptions(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+	output := t.TempDir()
+	other := t.TempDir()
+
+	a := fakePDF(t, dir, "a.pdf", 5, 0)
+	b := fakePDF(t, dir, "b.pdf", 5, 0, "Password: secret")
+	c := fakePDF(t, dir, "c.pdf", 3, 0)
+
+	for _, preflight := range []bool{false, true} {
+		options := []CallOption{
+			WithPopplerPath(poppler),
+			WithOutputFolder(output),
+			WithJob(2),
+		}
+		if preflight {
+			options = append(options, WithPreflight())
+		}
+
+		var logs bytes.Buffer
+		var mu sync.Mutex
+		outputs := map[string]int{}
+		options = append(options,
+			WithLogger(NewLogger(&logs, LevelDebug)),
+			WithOnPageDone(func(e PageEvent) {
+				mu.Lock()
+				defer mu.Unlock()
+				if strings.HasPrefix(e.Output, other) {
+					outputs[e.File]++
+				}
+			}),
+		)
+
+		task, err := ConvertFiles([]Item{
+			{File: a, Options: []CallOption{WithDpi(300), WithFirstPage(2), WithLastPage(3)}},
+			{File: b, Options: []CallOption{WithUserPw("secret"), WithOutputFolder(other)}},
+			{File: c},
+		}, options...)
+		assert.NoError(t, err, "conversion task initialization should not failed")
+		task.Wait()
+
+		assert.NoError(t, task.Error())
+		assert.EqualValues(t, 10, task.Pages.Finished())
+		if preflight {
+			assert.EqualValues(t, 10, task.Pages.Total())
+		}
+
+		report := task.Report()
+		assert.Equal(t, []int32{2, 3}, report.Files[a].Succeeded)
+		assert.Len(t, report.Files[b].Succeeded, 5)
+		assert.Len(t, report.Files[c].Succeeded, 3)
+
+		assert.Equal(t, map[string]int{b: 5}, outputs)
+
+		assert.Contains(t, logs.String(), "-r 300")
+		assert.Contains(t, logs.String(), "-r 200")
+	}
+
+	// an invalid per-file option fails only that file
+	task, err := ConvertFiles([]Item{
+		{File: a, Options: []CallOption{WithDpi(-1)}},
+		{File: c},
+	}, WithPopplerPath(poppler), WithOutputFolder(output))
+	assert.NoError(t, err, "conversion task initialization should not failed")
+	task.Wait()
+
+	var argumentError *WrongArgumentError
+	assert.ErrorAs(t, task.Error(), &argumentError)
+	report := task.Report()
+	assert.True(t, report.Files[a].Failed())
+	assert.False(t, report.Files[c].Failed())
+}
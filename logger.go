@@ -0,0 +1,224 @@
+package pico
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// Field is a key-value pair attached to a log record
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Logger is the structured logger used by pico, records carry fields like
+// task, file, worker and page. Implementations must be safe for concurrent
+// use, passwords are redacted before records reach the logger.
+type Logger interface {
+	Log(level Level, msg string, fields ...Field)
+}
+
+// LoggerFunc adapts a function to a Logger
+type LoggerFunc func(level Level, msg string, fields ...Field)
+
+func (fn LoggerFunc) Log(level Level, msg string, fields ...Field) {
+	fn(level, msg, fields...)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Log(Level, string, ...Field) {}
+
+// textLogger writes records in the logfmt style
+type textLogger struct {
+	mu  sync.Mutex
+	w   io.Writer
+	min Level
+}
+
+// NewLogger creates a Logger that writes records with level at least `min`
+// to `w` in the logfmt style, e.g.
+//
+//	time=2022-06-01T10:00:00Z level=info msg="file done" task=1 file=a.pdf worker=0
+func NewLogger(w io.Writer, min Level) Logger {
+	return &textLogger{w: w, min: min}
+}
+
+func (l *textLogger) Log(level Level, msg string, fields ...Field) {
+	if level < l.min {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("time=")
+	b.WriteString(time.Now().Format(time.RFC3339))
+	b.WriteString(" level=")
+	b.WriteString(level.String())
+	b.WriteString(" msg=")
+	b.WriteString(logfmtValue(msg))
+	for _, f := range fields {
+		b.WriteString(" ")
+		b.WriteString(f.Key)
+		b.WriteString("=")
+		b.WriteString(logfmtValue(fmt.Sprint(f.Value)))
+	}
+	b.WriteString("\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.w, b.String())
+}
+
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+const _redacted = "******"
+
+// redactingLogger replaces secrets in messages and field values before
+// passing records to the underlying logger. Secrets resolved while the task
+// is running, e.g. by a PasswordProvider, are added by `add`.
+type redactingLogger struct {
+	l Logger
+
+	mu      sync.RWMutex
+	secrets []string
+}
+
+func newRedactingLogger(l Logger, secrets ...string) Logger {
+	if l == nil {
+		return nopLogger{}
+	}
+	if _, ok := l.(nopLogger); ok {
+		return l
+	}
+
+	r := &redactingLogger{l: l}
+	r.add(secrets...)
+	return r
+}
+
+// add registers the secrets to redact, it is safe for concurrent use
+func (r *redactingLogger) add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, secret := range secrets {
+		if secret != "" && !r.known(secret) {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+}
+
+func (r *redactingLogger) known(secret string) bool {
+	for _, s := range r.secrets {
+		if s == secret {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *redactingLogger) redact(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, secret := range r.secrets {
+		s = strings.Replace(s, secret, _redacted, -1)
+	}
+	return s
+}
+
+func (r *redactingLogger) Log(level Level, msg string, fields ...Field) {
+	redacted := make([]Field, len(fields))
+	for i, f := range fields {
+		switch v := f.Value.(type) {
+		case []string:
+			f.Value = r.redact(strings.Join(redactCommand(v), " "))
+		case string:
+			f.Value = r.redact(v)
+		case error:
+			f.Value = r.redact(v.Error())
+		}
+		redacted[i] = f
+	}
+
+	r.l.Log(level, r.redact(msg), redacted...)
+}
+
+// redactCommand hides the value of password arguments of a command line
+func redactCommand(command []string) []string {
+	redacted := append([]string{}, command...)
+	for i := 0; i < len(redacted)-1; i++ {
+		switch redacted[i] {
+		case "-upw", "-opw":
+			redacted[i+1] = _redacted
+			i++
+		}
+	}
+	return redacted
+}
+
+// setupLogger wraps the logger given by `WithLogger` (or the verbose logger)
+// so that passwords never leak into logs.
+func (p *Parameters) setupLogger() {
+	if _, ok := p.logger.(*redactingLogger); ok {
+		return
+	}
+	if p.logger == nil && p.verbose {
+		p.logger = NewLogger(os.Stderr, LevelDebug)
+	}
+	p.logger = newRedactingLogger(p.logger, p.userPw, p.ownerPw)
+}
+
+// redactPasswords registers the passwords as secrets of the logger, it must be
+// called before the passwords are passed to any command
+func (p *Parameters) redactPasswords(passwords ...Password) {
+	r, ok := p.logger.(*redactingLogger)
+	if !ok {
+		return
+	}
+	for _, pw := range passwords {
+		r.add(pw.User, pw.Owner)
+	}
+}
+
+// WithLogger sets the logger, every spawned command, poppler stderr output
+// and error is logged with task, file, worker and page fields.
+func WithLogger(l Logger) CallOption {
+	return func(p *Parameters, command []string) []string {
+		p.logger = l
+		return command
+	}
+}
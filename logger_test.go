@@ -0,0 +1,52 @@
+package pico
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggerRedactsPasswords(t *testing.T) {
+	cases := []struct {
+		name     string
+		kind     string
+		extra    []string
+		options  []CallOption
+		contains []string
+	}{
+		{
+			name:     "static",
+			kind:     "SingleTask",
+			options:  []CallOption{WithUserPw("s3cr3t-user"), WithOwnerPw("s3cr3t-owner")},
+			contains: []string{`msg="spawn command"`, "-upw ******", `msg="page done"`},
+		},
+		{
+			// passwords resolved per file are redacted from poppler stderr
+			name:     "provided",
+			kind:     "BatchTask",
+			extra:    []string{"Password: s3cr3t-provided", "Stderr: 1 Syntax Warning: wrong password s3cr3t-provided"},
+			options:  []CallOption{WithPasswordProvider(PasswordCandidates{"s3cr3t-wrong", "s3cr3t-provided"})},
+			contains: []string{"-upw ******", "wrong password ******"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pdf := fakePDF(t, t.TempDir(), "locked.pdf", 3, 0, c.extra...)
+
+			var buf bytes.Buffer
+			task, err := converts[c.kind]([]string{pdf},
+				fakeOptions(t, append(c.options, WithLogger(NewLogger(&buf, LevelDebug)))...)...)
+			assert.NoError(t, err, "conversion task initialization should not failed")
+			task.Wait()
+			assert.Empty(t, task.Errors())
+
+			logs := buf.String()
+			for _, s := range c.contains {
+				assert.Contains(t, logs, s)
+			}
+			assert.NotContains(t, logs, "s3cr3t")
+		})
+	}
+}
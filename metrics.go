@@ -0,0 +1,401 @@
+package pico
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultLatencyBuckets are the upper bounds (in seconds) of the page latency
+// histogram buckets
+var DefaultLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// metric types of the text exposition format
+const (
+	MetricCounter   = "counter"
+	MetricGauge     = "gauge"
+	MetricHistogram = "histogram"
+)
+
+// Bucket is a cumulative histogram bucket
+type Bucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// Sample is a single labeled value of a metric family, Buckets, Count and Sum
+// are only set for histograms
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+
+	Buckets []Bucket
+	Count   uint64
+	Sum     float64
+}
+
+// MetricFamily is a snapshot of all the samples of a metric
+type MetricFamily struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// vec stores the values of a metric family indexed by label values
+type vec struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	// buckets is only used by histograms
+	buckets []float64
+
+	samples map[string]*sample
+}
+
+type sample struct {
+	values []string
+	value  float64
+
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newVec(name, typ, help string, labels ...string) *vec {
+	return &vec{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  labels,
+		samples: map[string]*sample{},
+	}
+}
+
+func (v *vec) get(values []string) *sample {
+	key := strings.Join(values, "\xff")
+	s, ok := v.samples[key]
+	if !ok {
+		s = &sample{values: values, counts: make([]uint64, len(v.buckets))}
+		v.samples[key] = s
+	}
+	return s
+}
+
+func (v *vec) add(delta float64, values ...string) {
+	v.get(values).value += delta
+}
+
+func (v *vec) observe(x float64, values ...string) {
+	s := v.get(values)
+	for i, bound := range v.buckets {
+		if x <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += x
+}
+
+func (v *vec) family() MetricFamily {
+	f := MetricFamily{Name: v.name, Help: v.help, Type: v.typ}
+
+	keys := make([]string, 0, len(v.samples))
+	for key := range v.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := v.samples[key]
+		labels := map[string]string{}
+		for i, name := range v.labels {
+			labels[name] = s.values[i]
+		}
+
+		out := Sample{Labels: labels, Value: s.value}
+		if v.typ == MetricHistogram {
+			for i, bound := range v.buckets {
+				out.Buckets = append(out.Buckets, Bucket{UpperBound: bound, Count: s.counts[i]})
+			}
+			out.Count, out.Sum = s.count, s.sum
+		}
+		f.Samples = append(f.Samples, out)
+	}
+
+	return f
+}
+
+// Metrics is a registry of conversion metrics, it could be shared by many
+// tasks through `WithMetrics` and exposed by `Handler()`. Every metric is
+// labeled by backend (pdftoppm or pdftocairo), format and task kind.
+type Metrics struct {
+	mu sync.Mutex
+
+	pages   *vec
+	files   *vec
+	errors  *vec
+	spawns  *vec
+	latency *vec
+	workers *vec
+	tasks   *vec
+}
+
+// NewMetrics creates a registry, `buckets` are the upper bounds in seconds of
+// the page latency histogram, DefaultLatencyBuckets is used if none given.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	m := &Metrics{
+		pages: newVec("pico_pages_rendered_total", MetricCounter,
+			"Number of pages rendered.", "backend", "format", "kind"),
+		files: newVec("pico_files_processed_total", MetricCounter,
+			"Number of files processed.", "backend", "format", "kind", "status"),
+		errors: newVec("pico_errors_total", MetricCounter,
+			"Number of conversion errors by type.", "backend", "format", "kind", "type"),
+		spawns: newVec("pico_spawn_failures_total", MetricCounter,
+			"Number of poppler subprocesses that failed to spawn.", "backend", "format", "kind"),
+		latency: newVec("pico_page_duration_seconds", MetricHistogram,
+			"Time spent on rendering a page.", "backend", "format", "kind"),
+		workers: newVec("pico_workers_active", MetricGauge,
+			"Number of running convertors.", "backend", "format", "kind"),
+		tasks: newVec("pico_tasks_total", MetricCounter,
+			"Number of tasks completed.", "backend", "format", "kind"),
+	}
+	m.latency.buckets = buckets
+
+	return m
+}
+
+func (m *Metrics) vecs() []*vec {
+	return []*vec{m.pages, m.files, m.errors, m.spawns, m.latency, m.workers, m.tasks}
+}
+
+// Gather returns a snapshot of all the metric families
+func (m *Metrics) Gather() []MetricFamily {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	families := []MetricFamily{}
+	for _, v := range m.vecs() {
+		families = append(families, v.family())
+	}
+	return families
+}
+
+// WriteTo writes all the metrics in the Prometheus text exposition format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	for _, f := range m.Gather() {
+		fmt.Fprintf(cw, "# HELP %s %s\n", f.Name, f.Help)
+		fmt.Fprintf(cw, "# TYPE %s %s\n", f.Name, f.Type)
+
+		for _, s := range f.Samples {
+			if f.Type != MetricHistogram {
+				fmt.Fprintf(cw, "%s%s %s\n", f.Name, formatLabels(s.Labels, "", ""), formatFloat(s.Value))
+				continue
+			}
+
+			for _, b := range s.Buckets {
+				fmt.Fprintf(cw, "%s_bucket%s %d\n", f.Name,
+					formatLabels(s.Labels, "le", formatFloat(b.UpperBound)), b.Count)
+			}
+			fmt.Fprintf(cw, "%s_bucket%s %d\n", f.Name, formatLabels(s.Labels, "le", "+Inf"), s.Count)
+			fmt.Fprintf(cw, "%s_sum%s %s\n", f.Name, formatLabels(s.Labels, "", ""), formatFloat(s.Sum))
+			fmt.Fprintf(cw, "%s_count%s %d\n", f.Name, formatLabels(s.Labels, "", ""), s.Count)
+		}
+	}
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+// Handler serves the metrics in the Prometheus text exposition format
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.WriteTo(w)
+	})
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
+
+func formatLabels(labels map[string]string, extraName, extraValue string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := []string{}
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, strconv.Quote(labels[name])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", extraName, strconv.Quote(extraValue)))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// taskMetrics feeds the metrics of a task, it is a no-op if the task has no
+// metrics registry.
+type taskMetrics struct {
+	m *Metrics
+
+	// labels are the values of backend, format and kind
+	labels []string
+}
+
+func newTaskMetrics(m *Metrics, p *Parameters, kind string) *taskMetrics {
+	format, _, _ := parseFormat(p.fmt, p.grayscale)
+	return &taskMetrics{m: m, labels: []string{p.binary, format, kind}}
+}
+
+func (tm *taskMetrics) with(values ...string) []string {
+	return append(append([]string{}, tm.labels...), values...)
+}
+
+func (tm *taskMetrics) update(fn func(m *Metrics)) {
+	if tm == nil || tm.m == nil {
+		return
+	}
+	tm.m.mu.Lock()
+	defer tm.m.mu.Unlock()
+	fn(tm.m)
+}
+
+func (tm *taskMetrics) pageDone(e PageEvent) {
+	tm.update(func(m *Metrics) {
+		m.pages.add(1, tm.labels...)
+		m.latency.observe(e.Duration.Seconds(), tm.labels...)
+	})
+}
+
+func (tm *taskMetrics) fileDone(e FileEvent) {
+	status := "ok"
+	if e.Err != nil {
+		status = "failed"
+	}
+	tm.update(func(m *Metrics) { m.files.add(1, tm.with(status)...) })
+}
+
+func (tm *taskMetrics) error(err *ConversionError) {
+	tm.update(func(m *Metrics) { m.errors.add(1, tm.with(errorType(err))...) })
+}
+
+func (tm *taskMetrics) spawnFailed() {
+	tm.update(func(m *Metrics) { m.spawns.add(1, tm.labels...) })
+}
+
+func (tm *taskMetrics) workers(delta float64) {
+	tm.update(func(m *Metrics) { m.workers.add(delta, tm.labels...) })
+}
+
+func (tm *taskMetrics) taskDone() {
+	tm.update(func(m *Metrics) { m.tasks.add(1, tm.labels...) })
+}
+
+// errorType names the category of an error for the `type` label
+func errorType(err error) string {
+	var syntaxError *PDFSyntaxError
+	var argumentError *WrongArgumentError
+	var timeoutError *PerPageTimeoutError
+	var exitError *exec.ExitError
+	var pathError *os.PathError
+
+	var lockedError *LockedError
+	var budgetError *BudgetError
+	var incorrectPasswordError *IncorrectPasswordError
+	var encryptedError *EncryptedError
+	var permissionError *PermissionError
+	var damagedFileError *DamagedFileError
+	var unsupportedFeatureError *UnsupportedFeatureError
+	var outOfMemoryError *OutOfMemoryError
+	var ioError *IOError
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	case errors.As(err, &budgetError):
+		return "budget"
+	case errors.As(err, &lockedError):
+		return "locked"
+	case errors.As(err, &incorrectPasswordError):
+		return "incorrect_password"
+	case errors.As(err, &encryptedError):
+		return "encrypted"
+	case errors.As(err, &permissionError):
+		return "permission"
+	case errors.As(err, &damagedFileError):
+		return "damaged"
+	case errors.As(err, &unsupportedFeatureError):
+		return "unsupported"
+	case errors.As(err, &outOfMemoryError):
+		return "out_of_memory"
+	case errors.As(err, &ioError):
+		return "io"
+	case errors.As(err, &syntaxError):
+		return "syntax"
+	case errors.As(err, &argumentError):
+		return "wrong_argument"
+	case errors.As(err, &timeoutError):
+		return "timeout"
+	case errors.As(err, &exitError):
+		return "exit"
+	case errors.As(err, &pathError):
+		return "io"
+	default:
+		return "other"
+	}
+}
+
+// WithMetrics feeds the metrics of the conversion into the registry
+func WithMetrics(m *Metrics) CallOption {
+	return func(p *Parameters, command []string) []string {
+		p.metrics = m
+		return command
+	}
+}
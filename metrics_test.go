@@ -0,0 +1,70 @@
+package pico
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	cases := []struct {
+		kind    string
+		pages   []int
+		missing bool
+		labels  string
+		lines   []string
+	}{
+		{"SingleTask", []int{6}, false, `backend="pdftoppm",format="ppm",kind="single"`, []string{
+			`pico_pages_rendered_total{%s} 6`,
+			`pico_files_processed_total{%s,status="ok"} 2`,
+			`pico_page_duration_seconds_count{%s} 6`,
+			`pico_workers_active{%s} 0`,
+			`pico_tasks_total{%s} 1`,
+		}},
+		// files failed to start are reported as done too
+		{"BatchTask", []int{3, 4}, true, `backend="pdftoppm",format="ppm",kind="batch"`, []string{
+			`pico_pages_rendered_total{%s} 7`,
+			`pico_files_processed_total{%s,status="ok"} 2`,
+			`pico_files_processed_total{%s,status="failed"} 1`,
+			`pico_errors_total{%s,type="io"} 1`,
+			`pico_page_duration_seconds_count{%s} 7`,
+			`pico_workers_active{%s} 0`,
+			`pico_tasks_total{%s} 1`,
+		}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.kind, func(t *testing.T) {
+			metrics := NewMetrics()
+			files := fakeFiles(t, 0, c.pages...)
+			if c.missing {
+				files = append(files, filepath.Join(t.TempDir(), "missing.pdf"))
+			}
+
+			var failed error
+			task, err := converts[c.kind](files, fakeOptions(t,
+				WithJob(2),
+				WithMetrics(metrics),
+				WithOnFileDone(func(e FileEvent) {
+					if filepath.Base(e.File) == "missing.pdf" {
+						failed = e.Err
+					}
+				}),
+			)...)
+			assert.NoError(t, err, "conversion task initialization should not failed")
+			task.Wait()
+			assert.Equal(t, c.missing, errors.Is(failed, os.ErrNotExist))
+
+			rec := httptest.NewRecorder()
+			metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+			for _, line := range c.lines {
+				assert.Contains(t, rec.Body.String(), fmt.Sprintf(line, c.labels)+"\n")
+			}
+		})
+	}
+}
@@ -3,10 +3,10 @@ package pico
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -36,6 +36,11 @@ type Parameters struct {
 	options     []CallOption
 	timeout     time.Duration
 
+	// passwordProvider resolves candidate passwords per file, and passwords
+	// caches the one that opened each file
+	passwordProvider PasswordProvider
+	passwords        *passwordCache
+
 	// These fields are used by Convert Function
 	dpi             int
 	firstPage       int32
@@ -56,13 +61,51 @@ type Parameters struct {
 	usePdftocario   bool
 	hideAnnotations bool
 
+	// logger is always set after options are applied
+	logger Logger
+
+	// tracer is always set after options are applied
+	tracer Tracer
+
+	// metrics is the registry fed by the conversion
+	metrics *Metrics
+
+	// hooks are the callbacks registered by `WithOnXXX` options
+	hooks hooks
+
+	// orderedBuffer is the per-segment buffer size used to deliver entries in
+	// page order, zero means entries are delivered as soon as they arrive
+	orderedBuffer int32
+
 	scaleTo  int
 	scaleToX int
 	scaleToY int
 
-	// these are what must be computed
+	// preflight counts the pages of all the files before conversion
+	preflight bool
+
+	// byteBudget and fileByteBudget limit the bytes written by the task and
+	// for every file, zero means unlimited
+	byteBudget     int64
+	fileByteBudget int64
+	freeSpaceCheck bool
+
+	// pageCounts caches the page count of files, and pageInfos the pdfinfo
+	// entries with page sizes of files when the output is estimated, they are
+	// only written before the convertors start
+	pageCounts map[string]int32
+	pageInfos  map[string]map[string]string
+
+	// argErrors are the invalid arguments found by options, they are
+	// reported by apply()
+	argErrors []string
+
+	// these are what must be computed, optionArgs are the arguments added by
+	// options and version is the version of the binary
 	baseCommand       []string
+	optionArgs        []string
 	binary            string
+	version           []int
 	pageCount         int32
 	minPagesPerWorker int32
 
@@ -71,6 +114,12 @@ type Parameters struct {
 
 	// this field is only used by GetPDFInfo() call
 	rawDates bool
+
+	// pageSizes lists the size of every page, only used by Preflight() call
+	pageSizes bool
+
+	// textLayout keeps the physical layout, only used by GetText() call
+	textLayout bool
 }
 
 // pageRangeForPart calculates the page range needed to be converted for a given file
@@ -78,13 +127,14 @@ type Parameters struct {
 func (p *Parameters) pageRangeForPart(pdf string, index int32) (int32, int32, error) {
 	reminder := p.pageCount % p.job
 
-	amortization := int32(0)
+	// the first `reminder` workers take one more page each
+	amortization, shift := int32(0), reminder
 	if index < reminder {
-		amortization = 1
+		amortization, shift = 1, index
 	}
 
-	first := p.firstPage + index*p.minPagesPerWorker
-	last := first + reminder + p.minPagesPerWorker - 1 + amortization
+	first := p.firstPage + index*p.minPagesPerWorker + shift
+	last := first + p.minPagesPerWorker - 1 + amortization
 
 	// FIXME: seems redundant
 	if last > p.lastPage {
@@ -94,17 +144,47 @@ func (p *Parameters) pageRangeForPart(pdf string, index int32) (int32, int32, er
 	return first, last, nil
 }
 
-// pagePrangeForFile calculates the page range needed to be converted for a given file
-// by specific worker during ConvertFiles() call.
-func (p *Parameters) pageRangeForFile(pdf string, index int32) (int32, int32, error) {
-	first, last := p.firstPage, p.lastPage
+// fileInfo returns the page count of the pdf, and its pdfinfo entries with
+// page sizes if the output is estimated for the budgets. Results of the
+// preflight are reused, otherwise pdfinfo is run once.
+func (p *Parameters) fileInfo(pdf string) (int32, map[string]string, error) {
+	pages, ok := p.pageCounts[pdf]
+	infos := p.pageInfos[pdf]
+	if ok && (infos != nil || !p.budgeted()) {
+		return pages, infos, nil
+	}
+	return p.pdfInfo(pdf)
+}
+
+// pdfInfo runs pdfinfo for the pdf with the options of the parameters, page
+// sizes are asked for if the output is estimated for the budgets. The
+// password that opened the pdf is remembered.
+func (p *Parameters) pdfInfo(pdf string, options ...CallOption) (int32, map[string]string, error) {
+	options = append(append([]CallOption{}, p.options...), options...)
+	if p.budgeted() {
+		options = append(options, withPageSizes())
+	}
 
-	pages, err := GetPagesCount(pdf, p.options...)
+	infos, pw, err := getInfo(pdf, options...)
 	if err != nil {
-		return 0, 0, errors.Wrap(err, "failed to get pages count ")
+		return 0, nil, err
+	}
+	pages, err := pagesOf(infos)
+	if err != nil {
+		return 0, nil, err
+	}
+	p.rememberPassword(pdf, pw)
+
+	if !p.budgeted() {
+		infos = nil
 	}
+	return int32(pages), infos, nil
+}
 
-	totalPage := int32(pages)
+// pageRangeForFile calculates the page range needed to be converted for a
+// file with `totalPage` pages during ConvertFiles() call.
+func (p *Parameters) pageRangeForFile(totalPage int32) (int32, int32, error) {
+	first, last := p.firstPage, p.lastPage
 
 	if last < 0 || last > totalPage {
 		last = totalPage
@@ -117,10 +197,9 @@ func (p *Parameters) pageRangeForFile(pdf string, index int32) (int32, int32, er
 	return first, last, nil
 }
 
-// buildCommand builds the full command line to convert (part of) a PDF file,
-// outputFile and outputFolder are calculated during this call based on the
-// index of the worker/convertor.
-func (p *Parameters) buildCommand(pdf string, index, first, last int32) []string {
+// outputPath returns the output path (without page number and extension) of
+// the pdf converted by worker `index` from `first` to `last`
+func (p *Parameters) outputPath(pdf string, index, first, last int32) string {
 	outputFile := p.outputFile
 	if outputFile == "" {
 		ext := path.Ext(pdf)
@@ -136,7 +215,14 @@ func (p *Parameters) buildCommand(pdf string, index, first, last int32) []string
 		outputFolder = p.outputFolderFn(pdf, index, first, last)
 	}
 
-	outputFile = filepath.Join(outputFolder, outputFile)
+	return filepath.Join(outputFolder, outputFile)
+}
+
+// buildCommand builds the full command line to convert (part of) a PDF file,
+// outputFile and outputFolder are calculated during this call based on the
+// index of the worker/convertor.
+func (p *Parameters) buildCommand(pdf string, index, first, last int32) []string {
+	outputFile := p.outputPath(pdf, index, first, last)
 	os.MkdirAll(filepath.Dir(outputFile), 0755)
 
 	command := []string{
@@ -146,23 +232,118 @@ func (p *Parameters) buildCommand(pdf string, index, first, last int32) []string
 		"-l", strconv.Itoa(int(last)),
 	}
 	command = append(command, p.baseCommand...)
+	command = append(command, p.passwordFor(pdf).args()...)
 	command = append(command, pdf, outputFile)
 
 	return command
 }
 
+// invalid records an invalid argument which is reported by apply()
+func (p *Parameters) invalid(format string, args ...interface{}) {
+	p.argErrors = append(p.argErrors, fmt.Sprintf(format, args...))
+}
+
+// validate reports the invalid arguments as a *WrongArgumentError
+func (p *Parameters) validate() error {
+	if _, ok := _formats[strings.TrimPrefix(strings.ToLower(p.fmt), ".")]; !ok {
+		p.invalid("unsupported format %q", p.fmt)
+	}
+	if p.dpi <= 0 {
+		p.invalid("dpi must be positive, got %d", p.dpi)
+	}
+	if p.scaleTo < 0 || p.scaleToX < 0 || p.scaleToY < 0 {
+		p.invalid("scale must not be negative")
+	}
+	if p.byteBudget < 0 || p.fileByteBudget < 0 {
+		p.invalid("byte budget must not be negative")
+	}
+
+	if len(p.argErrors) > 0 {
+		return newWrongArgumentError(strings.Join(p.argErrors, "; "))
+	}
+	return nil
+}
+
+// _formats are the supported output formats
+var _formats = map[string]bool{
+	"ppm":  true,
+	"jpeg": true,
+	"jpg":  true,
+	"png":  true,
+	"tiff": true,
+	"tif":  true,
+}
+
+// formatJPEGOpt formats the jpeg options in the form of `-jpegopt`, keys are
+// sorted so that the command is stable
+func formatJPEGOpt(jpegOpt map[string]string) string {
+	keys := make([]string, 0, len(jpegOpt))
+	for k := range jpegOpt {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%s", k, jpegOpt[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+// setJPEGOpt sets a jpeg option
+func (p *Parameters) setJPEGOpt(key, value string) {
+	if p.jpegOpt == nil {
+		p.jpegOpt = map[string]string{}
+	}
+	p.jpegOpt[key] = value
+}
+
 func (p *Parameters) apply(options ...CallOption) error {
 	command := []string{}
 	for _, option := range options {
 		command = option(p, command)
 	}
 
+	p.setupLogger()
+	p.passwords = &passwordCache{}
+	if p.tracer == nil {
+		p.tracer = nopTracer{}
+	}
+
+	base, err := p.buildBaseCommand(nil)
+	if err != nil {
+		return err
+	}
+
+	// the task context is always derived from the given one, so that the
+	// task could be cancelled without cancelling the caller's context
+	if p.timeout > 0 {
+		p.ctx, p.cancel = context.WithTimeout(p.ctx, p.timeout)
+	} else {
+		p.ctx, p.cancel = context.WithCancel(p.ctx)
+	}
+	p.options = options
+	p.optionArgs = command
+	p.baseCommand = append(command, base...)
+
+	return nil
+}
+
+// buildBaseCommand validates the parameters and builds the arguments shared
+// by every command. The version of the binary is reused from `known` if it
+// uses the same binary, or detected otherwise.
+func (p *Parameters) buildBaseCommand(known *Parameters) ([]string, error) {
+	if err := p.validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
 	if p.usePdftocario && p.fmt == "ppm" {
 		p.fmt = "png"
 	}
 
 	parsedFormat, _, usePdfcairoFormat := parseFormat(p.fmt, p.grayscale)
 
+	command := []string{}
 	switch parsedFormat {
 	case "jpeg":
 		command = append(command, "-jpeg")
@@ -179,11 +360,22 @@ func (p *Parameters) apply(options ...CallOption) error {
 		p.binary = "pdftocairo"
 	}
 
+	// only pdftocairo keeps the background transparent
+	if usePdfCairo && p.transparent && transparentFileType[parsedFormat] {
+		command = append(command, "-transp")
+	}
+
 	// this considered as a Fatal if we cannot get the version of poppler utilities
-	version, err := getPopplerVersion(p.ctx, p.binary, p.popplerPath)
-	if err != nil {
-		return errors.WithStack(err)
+	version := []int(nil)
+	if known != nil && known.binary == p.binary && known.popplerPath == p.popplerPath {
+		version = known.version
+	} else {
+		var err error
+		if version, err = getPopplerVersion(p.ctx, p.binary, p.popplerPath); err != nil {
+			return nil, errors.WithStack(err)
+		}
 	}
+	p.version = version
 
 	major, minor := version[0], version[1]
 
@@ -197,10 +389,24 @@ func (p *Parameters) apply(options ...CallOption) error {
 	}
 
 	if usePdfCairo && p.hideAnnotations {
-		return errors.WithStack(
+		return nil, errors.WithStack(
 			newWrongArgumentError("hideAnnotations is not supported with pdftocairo"))
 	}
 
+	command = append(command, "-r", strconv.Itoa(p.dpi))
+
+	if parsedFormat == "jpeg" && len(p.jpegOpt) > 0 {
+		command = append(command, "-jpegopt", formatJPEGOpt(p.jpegOpt))
+	}
+
+	if p.useCropBox {
+		command = append(command, "-cropbox")
+	}
+
+	if p.hideAnnotations {
+		command = append(command, "-hide-annotations")
+	}
+
 	// size related options
 	if p.scaleTo > 0 {
 		command = append(command, "-scale-to", strconv.Itoa(p.scaleTo))
@@ -213,14 +419,7 @@ func (p *Parameters) apply(options ...CallOption) error {
 		}
 	}
 
-	// ctx
-	if p.timeout > 0 {
-		p.ctx, p.cancel = context.WithTimeout(p.ctx, p.timeout)
-	}
-	p.options = options
-	p.baseCommand = command
-
-	return nil
+	return command, nil
 }
 
 // WithPopplerPath sets poppler binaries lookup path
@@ -235,7 +434,7 @@ func WithPopplerPath(popplerPath string) CallOption {
 func WithUserPw(userPw string) CallOption {
 	return func(p *Parameters, command []string) []string {
 		p.userPw = userPw
-		return append(command, "-upw", userPw)
+		return command
 	}
 }
 
@@ -243,7 +442,7 @@ func WithUserPw(userPw string) CallOption {
 func WithOwnerPw(ownerPw string) CallOption {
 	return func(p *Parameters, command []string) []string {
 		p.ownerPw = ownerPw
-		return append(command, "-opw", ownerPw)
+		return command
 	}
 }
 
@@ -339,12 +538,14 @@ func WithFormat(fmt string) CallOption {
 	}
 }
 
+// WithJPEGQuality sets the jpeg quality from 0 to 100
 func WithJPEGQuality(quality int) CallOption {
 	return func(p *Parameters, command []string) []string {
 		if quality < 0 || quality > 100 {
-			quality = 75
+			p.invalid("jpeg quality must be in [0, 100], got %d", quality)
+			return command
 		}
-		p.jpegOpt["quality"] = strconv.Itoa(quality)
+		p.setJPEGOpt("quality", strconv.Itoa(quality))
 		return command
 	}
 }
@@ -352,9 +553,9 @@ func WithJPEGQuality(quality int) CallOption {
 func WithJPEGOptimize(optimize bool) CallOption {
 	return func(p *Parameters, command []string) []string {
 		if optimize {
-			p.jpegOpt["optimize"] = "y"
+			p.setJPEGOpt("optimize", "y")
 		} else {
-			p.jpegOpt["optimize"] = "n"
+			p.setJPEGOpt("optimize", "n")
 		}
 		return command
 	}
@@ -363,30 +564,26 @@ func WithJPEGOptimize(optimize bool) CallOption {
 func WithJPEGProgressive(progressive bool) CallOption {
 	return func(p *Parameters, command []string) []string {
 		if progressive {
-			p.jpegOpt["progressive"] = "y"
+			p.setJPEGOpt("progressive", "y")
 		} else {
-			p.jpegOpt["progressive"] = "n"
+			p.setJPEGOpt("progressive", "n")
 		}
 		return command
 	}
 }
 
+// WithJPEGOpt sets the jpeg options, valid keys are quality, optimize and
+// progressive
 func WithJPEGOpt(jpegOpt map[string]string) CallOption {
-	for k := range jpegOpt {
-		if _, ok := jpegOptMap[k]; !ok {
-			log.Fatal("Invalid JPEG option: " + k)
-		}
-	}
-
 	return func(p *Parameters, command []string) []string {
-		p.jpegOpt = jpegOpt
-
-		parts := []string{}
 		for k, v := range jpegOpt {
-			parts = append(parts, fmt.Sprintf("%s=%s", k, v))
+			if _, ok := jpegOptMap[k]; !ok {
+				p.invalid("invalid jpeg option %q", k)
+				continue
+			}
+			p.setJPEGOpt(k, v)
 		}
-
-		return append(command, "-jpegopt", strings.Join(parts, ","))
+		return command
 	}
 }
 
@@ -445,7 +642,7 @@ func WithTransparent() CallOption {
 func WithGrayScale() CallOption {
 	return func(p *Parameters, command []string) []string {
 		p.grayscale = true
-		return append(command, "-grayscale")
+		return append(command, "-gray")
 	}
 }
 
@@ -470,6 +667,35 @@ func WithHideAnnotations() CallOption {
 	}
 }
 
+// WithPreflight counts the pages of all the files before ConvertFiles() starts
+// the conversion, so that the task progress in pages has an accurate total
+// from the beginning. The files are collected from the provider first, which
+// means the conversion won't start until a channel provider is closed.
+func WithPreflight() CallOption {
+	return func(p *Parameters, command []string) []string {
+		p.preflight = true
+		return command
+	}
+}
+
+// WithOrderedEntries delivers entries through `Entries` in page order. For a
+// SingleTask, the pages of all convertors are yielded as 1, 2, 3...; for a
+// BatchTask, the pages of one file are yielded together and in order before
+// the next file starts.
+//
+// Each convertor buffers at most `buffer` entries while waiting for its turn,
+// a convertor whose buffer is full blocks until the entries ahead of it have
+// been delivered.
+func WithOrderedEntries(buffer int) CallOption {
+	if buffer < 1 {
+		buffer = 1
+	}
+	return func(p *Parameters, command []string) []string {
+		p.orderedBuffer = int32(buffer)
+		return command
+	}
+}
+
 func WithContext(ctx context.Context) CallOption {
 	return func(p *Parameters, command []string) []string {
 		p.ctx = ctx
@@ -0,0 +1,108 @@
+package pico
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageRangeForPart(t *testing.T) {
+	kases := []struct {
+		first, last int32
+		job         int32
+		expect      [][2]int32
+	}{
+		{1, 10, 3, [][2]int32{{1, 4}, {5, 7}, {8, 10}}},
+		{1, 12, 3, [][2]int32{{1, 4}, {5, 8}, {9, 12}}},
+		{1, 11, 3, [][2]int32{{1, 4}, {5, 8}, {9, 11}}},
+		{5, 9, 1, [][2]int32{{5, 9}}},
+		{22, 42, 4, [][2]int32{{22, 27}, {28, 32}, {33, 37}, {38, 42}}},
+		// Convert limits the workers to the pages, one page each
+		{1, 2, 2, [][2]int32{{1, 1}, {2, 2}}},
+	}
+
+	for _, kase := range kases {
+		pageCount := kase.last - kase.first + 1
+		p := &Parameters{
+			firstPage:         kase.first,
+			lastPage:          kase.last,
+			job:               kase.job,
+			pageCount:         pageCount,
+			minPagesPerWorker: pageCount / kase.job,
+		}
+
+		ranges := [][2]int32{}
+		for i := int32(0); i < kase.job; i++ {
+			first, last, err := p.pageRangeForPart("", i)
+			assert.NoError(t, err)
+			ranges = append(ranges, [2]int32{first, last})
+		}
+		assert.Equal(t, kase.expect, ranges, fmt.Sprintf("%d-%d over %d jobs", kase.first, kase.last, kase.job))
+	}
+}
+
+func TestCommandOptions(t *testing.T) {
+	kases := []struct {
+		name    string
+		options []CallOption
+		// contains are parts of the spawned command, errors are parts of
+		// the argument error
+		contains []string
+		errors   []string
+	}{
+		{
+			name: "jpeg",
+			options: []CallOption{
+				WithFormat("jpeg"),
+				WithDpi(150),
+				WithJPEGQuality(80),
+				WithJPEGOptimize(true),
+				WithUseCropBox(),
+				WithHideAnnotations(),
+				WithGrayScale(),
+			},
+			contains: []string{"-jpeg -r 150 -jpegopt optimize=y,quality=80 -cropbox -hide-annotations", " -gray "},
+		},
+		{
+			name:     "transparent",
+			options:  []CallOption{WithFormat("png"), WithTransparent()},
+			contains: []string{"pdftocairo -progress", "-png -transp "},
+		},
+		{
+			name: "invalid",
+			options: []CallOption{
+				WithFormat("bmp"),
+				WithJPEGQuality(200),
+				WithJPEGOpt(map[string]string{"smoothing": "1"}),
+			},
+			errors: []string{`unsupported format "bmp"`, "jpeg quality", `invalid jpeg option "smoothing"`},
+		},
+	}
+
+	for _, kase := range kases {
+		t.Run(kase.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			task, err := Convert(fakeFiles(t, 0, 2)[0],
+				fakeOptions(t, append(kase.options, WithLogger(NewLogger(&logs, LevelDebug)))...)...)
+
+			if kase.errors != nil {
+				var argumentError *WrongArgumentError
+				if assert.ErrorAs(t, err, &argumentError) {
+					for _, s := range kase.errors {
+						assert.Contains(t, err.Error(), s)
+					}
+				}
+				return
+			}
+
+			assert.NoError(t, err, "conversion task initialization should not failed")
+			task.Wait()
+			assert.NoError(t, task.Error())
+			for _, s := range kase.contains {
+				assert.Contains(t, logs.String(), s)
+			}
+		})
+	}
+}
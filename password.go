@@ -0,0 +1,164 @@
+package pico
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Password is a pair of user and owner passwords, either of them could open
+// an encrypted pdf
+type Password struct {
+	User  string
+	Owner string
+}
+
+func (pw Password) empty() bool {
+	return pw.User == "" && pw.Owner == ""
+}
+
+// args returns the poppler arguments of the password
+func (pw Password) args() []string {
+	args := []string{}
+	if pw.User != "" {
+		args = append(args, "-upw", pw.User)
+	}
+	if pw.Owner != "" {
+		args = append(args, "-opw", pw.Owner)
+	}
+	return args
+}
+
+// PasswordProvider resolves the candidate passwords of a pdf, candidates are
+// tried in order until one of them opens the file. It may be called from
+// multiple goroutines.
+type PasswordProvider interface {
+	Passwords(pdf string) []Password
+}
+
+// PasswordFunc adapts a function to a PasswordProvider
+type PasswordFunc func(pdf string) []Password
+
+func (fn PasswordFunc) Passwords(pdf string) []Password {
+	return fn(pdf)
+}
+
+// PasswordMap maps files to their user passwords, a file is looked up by its
+// path first and then by its base name
+type PasswordMap map[string]string
+
+func (m PasswordMap) Passwords(pdf string) []Password {
+	if pw, ok := m[pdf]; ok {
+		return []Password{{User: pw}}
+	}
+	if pw, ok := m[filepath.Base(pdf)]; ok {
+		return []Password{{User: pw}}
+	}
+	return nil
+}
+
+// PasswordCandidates tries every password as the user password of every file
+type PasswordCandidates []string
+
+func (c PasswordCandidates) Passwords(string) []Password {
+	passwords := make([]Password, len(c))
+	for i, pw := range c {
+		passwords[i] = Password{User: pw}
+	}
+	return passwords
+}
+
+// WithPasswordProvider resolves passwords per file, the password given by
+// `WithUserPw` and `WithOwnerPw` (if any) is tried first. A file is reported
+// with *LockedError only after every candidate has failed.
+func WithPasswordProvider(provider PasswordProvider) CallOption {
+	return func(p *Parameters, command []string) []string {
+		p.passwordProvider = provider
+		return command
+	}
+}
+
+// passwordCandidates returns the passwords to try for the pdf in order, they
+// are redacted from logs before any of them is tried
+func (p *Parameters) passwordCandidates(pdf string) []Password {
+	static := Password{User: p.userPw, Owner: p.ownerPw}
+	if p.passwordProvider == nil {
+		p.redactPasswords(static)
+		return []Password{static}
+	}
+
+	candidates := []Password{}
+	if !static.empty() {
+		candidates = append(candidates, static)
+	}
+	candidates = append(candidates, p.passwordProvider.Passwords(pdf)...)
+	if len(candidates) == 0 {
+		candidates = append(candidates, static)
+	}
+	p.redactPasswords(candidates...)
+	return candidates
+}
+
+// rememberPassword caches the password that opened the pdf, it is redacted
+// from logs before it is passed to any conversion command
+func (p *Parameters) rememberPassword(pdf string, pw Password) {
+	p.redactPasswords(pw)
+	p.passwords.set(pdf, pw)
+}
+
+// passwordFor returns the password that opened the pdf, or the static one if
+// the pdf has not been opened yet
+func (p *Parameters) passwordFor(pdf string) Password {
+	if pw, ok := p.passwords.get(pdf); ok {
+		return pw
+	}
+	return Password{User: p.userPw, Owner: p.ownerPw}
+}
+
+// passwordCache remembers the password that opened each file. A file is keyed
+// by its path, size and modification time, so a file replaced at the same
+// path while the task is running has its password resolved again.
+type passwordCache struct {
+	mu sync.Mutex
+	m  map[passwordKey]Password
+}
+
+type passwordKey struct {
+	path    string
+	size    int64
+	modTime int64
+}
+
+func passwordKeyOf(pdf string) passwordKey {
+	key := passwordKey{path: pdf}
+	if info, err := os.Stat(pdf); err == nil {
+		key.size, key.modTime = info.Size(), info.ModTime().UnixNano()
+	}
+	return key
+}
+
+func (c *passwordCache) get(pdf string) (Password, bool) {
+	if c == nil {
+		return Password{}, false
+	}
+	key := passwordKeyOf(pdf)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pw, ok := c.m[key]
+	return pw, ok
+}
+
+func (c *passwordCache) set(pdf string, pw Password) {
+	if c == nil {
+		return
+	}
+	key := passwordKeyOf(pdf)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.m == nil {
+		c.m = map[passwordKey]Password{}
+	}
+	c.m[key] = pw
+}
@@ -0,0 +1,112 @@
+package pico
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordCandidates(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+
+	alpha := fakePDF(t, dir, "alpha.pdf", 2, 0, "Password: alpha")
+	beta := fakePDF(t, dir, "beta.pdf", 3, 0, "Password: beta")
+	plain := fakePDF(t, dir, "plain.pdf", 1, 0)
+
+	var logs bytes.Buffer
+	task, err := ConvertFiles([]string{alpha, beta, plain},
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+		WithPasswordProvider(PasswordCandidates{"wrong", "alpha", "beta"}),
+		WithLogger(NewLogger(&logs, LevelDebug)),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+	task.Wait()
+
+	assert.NoError(t, task.Error())
+	assert.EqualValues(t, 6, task.Pages.Finished())
+	assert.Contains(t, logs.String(), "password rejected, trying next candidate")
+	assert.NotContains(t, logs.String(), "-upw alpha")
+	assert.NotContains(t, logs.String(), "-upw beta")
+
+	// a single file is unlocked before being split
+	single, err := Convert(beta,
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+		WithPasswordProvider(PasswordFunc(func(pdf string) []Password {
+			return []Password{{User: "wrong"}, {Owner: "beta"}}
+		})),
+		WithJob(3),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+	single.Wait()
+	assert.NoError(t, single.Error())
+	assert.EqualValues(t, 3, single.Pages.Finished())
+}
+
+func TestPasswordLocked(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+
+	alpha := fakePDF(t, dir, "alpha.pdf", 2, 0, "Password: alpha")
+	beta := fakePDF(t, dir, "beta.pdf", 3, 0, "Password: beta")
+
+	task, err := ConvertFiles([]string{alpha, beta},
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+		WithPasswordProvider(PasswordMap{filepath.Base(alpha): "alpha"}),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+	task.Wait()
+
+	report := task.Report()
+	assert.False(t, report.Files[alpha].Failed())
+	if errs := report.Files[beta].Errors[-1]; assert.Len(t, errs, 1) {
+		var lockedError *LockedError
+		var encryptedError *EncryptedError
+		assert.ErrorAs(t, errs[0], &lockedError)
+		assert.ErrorAs(t, errs[0], &encryptedError)
+		assert.Equal(t, beta, lockedError.File())
+		assert.Equal(t, 1, lockedError.Tried())
+	}
+
+	var lockedError *LockedError
+	var incorrectPasswordError *IncorrectPasswordError
+	_, err = GetInfo(beta, WithPopplerPath(poppler),
+		WithPasswordProvider(PasswordCandidates{"x", "y"}))
+	assert.ErrorAs(t, err, &lockedError)
+	assert.ErrorAs(t, err, &incorrectPasswordError)
+	assert.Equal(t, 2, lockedError.Tried())
+
+	// the static password is tried first
+	_, err = GetInfo(beta, WithPopplerPath(poppler), WithUserPw("beta"),
+		WithPasswordProvider(PasswordCandidates{"x"}))
+	assert.NoError(t, err)
+}
+
+func TestPasswordCache(t *testing.T) {
+	dir := t.TempDir()
+	pdf := fakePDF(t, dir, "a.pdf", 2, 0, "Password: alpha")
+
+	cache := &passwordCache{}
+	cache.set(pdf, Password{User: "alpha"})
+
+	pw, ok := cache.get(pdf)
+	assert.True(t, ok)
+	assert.Equal(t, Password{User: "alpha"}, pw)
+
+	_, ok = cache.get(filepath.Join(dir, "b.pdf"))
+	assert.False(t, ok)
+
+	// the file is replaced at the same path
+	fakePDF(t, dir, "a.pdf", 3, 0, "Password: beta")
+	later := time.Now().Add(time.Second)
+	assert.NoError(t, os.Chtimes(pdf, later, later))
+	_, ok = cache.get(pdf)
+	assert.False(t, ok)
+}
@@ -0,0 +1,155 @@
+package pico
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// _fakeArgs is shared by the fake utilities, it parses the arguments and
+// checks the password. A fake pdf could be locked by a `Password:` line which
+// must be given by either -upw or -opw.
+const _fakeArgs = `
+first=1
+last=1
+upw=
+opw=
+args=
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-f) first=$2; shift ;;
+	-l) last=$2; shift ;;
+	-upw) upw=$2; shift ;;
+	-opw) opw=$2; shift ;;
+	-r|-scale-to|-scale-to-x|-scale-to-y|-jpegopt|-x|-y|-W|-H) shift ;;
+	-*) ;;
+	*) args="$args $1" ;;
+	esac
+	shift
+done
+set -- $args
+
+if [ ! -f "$1" ]; then
+	echo "I/O Error: Couldn't open file '$1': No such file or directory." >&2
+	exit 1
+fi
+
+password=$(sed -n 's/^Password: //p' "$1")
+if [ -n "$password" ] && [ "$password" != "$upw" ] && [ "$password" != "$opw" ]; then
+	echo "Command Line Error: Incorrect password" >&2
+	exit 1
+fi
+`
+
+// _fakePdftoppm mimics `pdftoppm -progress`: it touches one output file per
+// page and reports the progress to stderr. It reads an optional `Delay:`
+// (seconds per page) line and `Stderr: <page> <line>` lines from the fake pdf.
+const _fakePdftoppm = `#!/bin/sh
+if [ "$1" = "-v" ]; then
+	echo "pdftoppm version 22.02.0" >&2
+	exit 0
+fi
+` + _fakeArgs + `
+pdf=$1
+out=$2
+delay=$(sed -n 's/^Delay: //p' "$pdf")
+
+i=$first
+while [ $i -le $last ]; do
+	if [ -n "$delay" ]; then sleep "$delay"; fi
+	sed -n "s/^Stderr: $i //p" "$pdf" >&2
+	echo x > "$out-$i.ppm"
+	echo "$i $last $out-$i.ppm" >&2
+	i=$((i+1))
+done
+`
+
+// _fakePdfinfo mimics `pdfinfo` by printing the fake pdf itself, which is
+// written in the `Key: value` form of pdfinfo's output.
+const _fakePdfinfo = `#!/bin/sh
+if [ "$1" = "-v" ]; then
+	echo "pdfinfo version 22.02.0" >&2
+	exit 0
+fi
+` + _fakeArgs + `
+grep -v -e '^Delay:' -e '^Password:' -e '^Stderr:' -e '^Text:' "$1"
+`
+
+// _fakePdftotext mimics `pdftotext` by printing the `Text:` lines of the fake
+// pdf to stdout
+const _fakePdftotext = `#!/bin/sh
+if [ "$1" = "-v" ]; then
+	echo "pdftotext version 22.02.0" >&2
+	exit 0
+fi
+` + _fakeArgs + `
+sed -n 's/^Text: //p' "$1"
+`
+
+// fakePoppler installs fake poppler utilities into a temporary folder and
+// returns it, which could be used with `WithPopplerPath`.
+func fakePoppler(t *testing.T) string {
+	dir := t.TempDir()
+	for name, script := range map[string]string{
+		"pdftoppm":   _fakePdftoppm,
+		"pdftocairo": _fakePdftoppm,
+		"pdfinfo":    _fakePdfinfo,
+		"pdftotext":  _fakePdftotext,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatalf("%+v", err)
+		}
+	}
+	return dir
+}
+
+// fakePDF writes a fake pdf with `pages` pages that renders each page in
+// `delay` seconds, `extra` lines like `Password: xyz` are appended.
+func fakePDF(t *testing.T, dir, name string, pages int, delay float64, extra ...string) string {
+	file := filepath.Join(dir, name)
+	content := fmt.Sprintf("Pages: %d\nDelay: %g\n", pages, delay)
+	for _, line := range extra {
+		content += line + "\n"
+	}
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	return file
+}
+
+// fakeFiles writes fake pdfs named a.pdf, b.pdf, ... with the given page
+// counts into a temporary folder
+func fakeFiles(t *testing.T, delay float64, pages ...int) []string {
+	dir := t.TempDir()
+	files := make([]string, len(pages))
+	for i, n := range pages {
+		files[i] = fakePDF(t, dir, fmt.Sprintf("%c.pdf", 'a'+i), n, delay)
+	}
+	return files
+}
+
+// fakeOptions converts with fake poppler utilities into a temporary folder,
+// `options` are appended
+func fakeOptions(t *testing.T, options ...CallOption) []CallOption {
+	return append([]CallOption{WithPopplerPath(fakePoppler(t)), WithOutputFolder(t.TempDir())}, options...)
+}
+
+// converts starts a SingleTask of the first file or a BatchTask of all the
+// files, for tests covering both kinds of tasks
+var converts = map[string]func(files []string, options ...CallOption) (*Task, error){
+	"SingleTask": func(files []string, options ...CallOption) (*Task, error) {
+		task, err := Convert(files[0], options...)
+		if err != nil {
+			return nil, err
+		}
+		return &task.Task, nil
+	},
+	"BatchTask": func(files []string, options ...CallOption) (*Task, error) {
+		task, err := ConvertFiles(files, options...)
+		if err != nil {
+			return nil, err
+		}
+		return &task.Task, nil
+	},
+}
@@ -0,0 +1,363 @@
+package pico
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// PageSize is the size of a page in points (1/72 inch), the rotation of the
+// page is already applied
+type PageSize struct {
+	Page   int32
+	Width  float64
+	Height float64
+}
+
+// PreflightReport is the result of the cheap checks run on a pdf before any
+// rendering happens
+type PreflightReport struct {
+	File   string
+	Exists bool
+	Size   int64
+
+	// Encrypted reports whether the pdf is encrypted, Locked reports whether
+	// none of the given passwords opens it
+	Encrypted bool
+	Locked    bool
+
+	PDFVersion string
+	Pages      int32
+
+	// PageSizes are the sizes of the pages to be converted
+	PageSizes []PageSize
+
+	// JavaScript and EmbeddedFiles report the presence of JavaScript actions
+	// and file attachments. They are detected from pdfinfo if supported, or
+	// from the uncompressed part of the file otherwise.
+	JavaScript    bool
+	EmbeddedFiles bool
+
+	// EstimatedPixels and EstimatedBytes estimate the output of the pages to
+	// be converted at the requested dpi, scale and format. The byte estimate
+	// of compressed formats is rough.
+	EstimatedPixels int64
+	EstimatedBytes  int64
+
+	// Err is the error that stopped the analysis, e.g. the file is missing,
+	// locked or damaged
+	Err error
+}
+
+// Preflight runs the cheap checks on a pdf before conversion, the options are
+// those of `Convert`. The returned error is also set to `Err` of the report,
+// invalid options are reported as a *WrongArgumentError.
+func Preflight(pdf string, options ...CallOption) (*PreflightReport, error) {
+	p := defaultConvertCallOption()
+	for _, option := range options {
+		option(p, nil)
+	}
+	p.setupLogger()
+
+	if err := p.validate(); err != nil {
+		err = errors.WithStack(err)
+		return &PreflightReport{File: pdf, Err: err}, err
+	}
+
+	report := p.analyze(pdf, options)
+	return report, report.Err
+}
+
+// PreflightFiles runs `Preflight` on multiple files concurrently, files could
+// be anything accepted by `ConvertFiles`. Reports are in the order the files
+// are provided. Invalid options are reported by `Err` of every report.
+func PreflightFiles(files interface{}, options ...CallOption) []*PreflightReport {
+	p := defaultConvertFilesCallOption()
+	for _, option := range options {
+		option(p, nil)
+	}
+	p.setupLogger()
+	argError := p.validate()
+
+	provider := FromInterface(files)
+
+	type job struct {
+		index int
+		pdf   string
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var reports []*PreflightReport
+
+	jobs := make(chan job)
+	for i := int32(0); i < p.job; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				var report *PreflightReport
+				if argError != nil {
+					report = &PreflightReport{File: j.pdf, Err: errors.WithStack(argError)}
+				} else {
+					report = p.analyze(j.pdf, options)
+				}
+				mu.Lock()
+				reports[j.index] = report
+				mu.Unlock()
+			}
+		}()
+	}
+
+	index := 0
+	for pdf := range provider.Source() {
+		mu.Lock()
+		reports = append(reports, nil)
+		mu.Unlock()
+		jobs <- job{index, pdf}
+		index++
+	}
+	close(jobs)
+	wg.Wait()
+
+	return reports
+}
+
+// withPageSizes makes pdfinfo report the size of every page in the page range
+func withPageSizes() CallOption {
+	return func(p *Parameters, command []string) []string {
+		p.pageSizes = true
+		return command
+	}
+}
+
+var _pageSizeKeyRE = regexp.MustCompile(`^Page\s+(\d+) size$`)
+var _pageSizeRE = regexp.MustCompile(`^([\d.]+) x ([\d.]+) pts`)
+var _pageRotKeyRE = regexp.MustCompile(`^Page\s+(\d+) rot$`)
+
+// analyze runs the preflight checks on a pdf
+func (p *Parameters) analyze(pdf string, options []CallOption) *PreflightReport {
+	report := &PreflightReport{File: pdf}
+
+	stat, err := os.Stat(pdf)
+	if err != nil {
+		report.Err = errors.WithStack(newIOError(err))
+		return report
+	}
+	report.Exists = true
+	report.Size = stat.Size()
+
+	infos, _, err := getInfo(pdf, append(options, withPageSizes())...)
+	if err != nil {
+		if isPasswordError(err) {
+			report.Encrypted = true
+			report.Locked = true
+		}
+		report.Err = err
+		p.logger.Log(LevelWarn, "preflight failed", Field{"file", pdf}, Field{"error", err})
+		return report
+	}
+
+	report.Encrypted = strings.HasPrefix(infos["Encrypted"], "yes")
+	report.PDFVersion = infos["PDF version"]
+	if pages, err := strconv.Atoi(infos["Pages"]); err == nil {
+		report.Pages = int32(pages)
+	}
+
+	report.PageSizes, report.EstimatedPixels, report.EstimatedBytes = p.estimate(infos, report.Pages)
+
+	report.JavaScript = infos["JavaScript"] == "yes"
+	if js, embedded, err := sniffPDF(pdf); err == nil {
+		report.JavaScript = report.JavaScript || js
+		report.EmbeddedFiles = embedded
+	}
+
+	p.logger.Log(LevelDebug, "preflight done", Field{"file", pdf}, Field{"pages", report.Pages},
+		Field{"estimated_bytes", report.EstimatedBytes})
+	return report
+}
+
+// estimate estimates the output of the pages to be converted from the page
+// sizes reported by pdfinfo
+func (p *Parameters) estimate(infos map[string]string, pages int32) (sizes []PageSize, pixels, bytes int64) {
+	first, last := p.pageRange(pages)
+	sizes = pageSizes(infos, first, last)
+	for _, size := range sizes {
+		n := p.outputPixels(size)
+		pixels += n
+		bytes += int64(float64(n) * p.bytesPerPixel())
+	}
+	return sizes, pixels, bytes
+}
+
+// pageRange returns the page range to be converted of a pdf with `pages` pages
+func (p *Parameters) pageRange(pages int32) (int32, int32) {
+	first, last := p.firstPage, p.lastPage
+	if first < 1 {
+		first = 1
+	}
+	if p.singleFile {
+		first, last = 1, 1
+	}
+	if last < 0 || last > pages {
+		last = pages
+	}
+	return first, last
+}
+
+// pageSizes collects the page sizes of pages from `first` to `last` reported
+// by pdfinfo, the size of the first page is used if pdfinfo does not report
+// the size of every page.
+func pageSizes(infos map[string]string, first, last int32) []PageSize {
+	sizes := map[int32]PageSize{}
+	rotations := map[int32]int{}
+
+	for key, value := range infos {
+		if m := _pageRotKeyRE.FindStringSubmatch(key); m != nil {
+			page, _ := strconv.Atoi(m[1])
+			rotations[int32(page)], _ = strconv.Atoi(value)
+			continue
+		}
+
+		var page int
+		switch m := _pageSizeKeyRE.FindStringSubmatch(key); {
+		case m != nil:
+			page, _ = strconv.Atoi(m[1])
+		case key == "Page size":
+			page = 0
+		default:
+			continue
+		}
+
+		if m := _pageSizeRE.FindStringSubmatch(value); m != nil {
+			w, _ := strconv.ParseFloat(m[1], 64)
+			h, _ := strconv.ParseFloat(m[2], 64)
+			sizes[int32(page)] = PageSize{Width: w, Height: h}
+		}
+	}
+
+	result := []PageSize{}
+	for page := first; page <= last; page++ {
+		size, ok := sizes[page]
+		if !ok {
+			if size, ok = sizes[0]; !ok {
+				continue
+			}
+		}
+		if rot := rotations[page]; rot == 90 || rot == 270 {
+			size.Width, size.Height = size.Height, size.Width
+		}
+		size.Page = page
+		result = append(result, size)
+	}
+	return result
+}
+
+// outputPixels estimates the pixels of a page rendered by pdftoppm, which
+// follows the scaling rules of pdftoppm
+func (p *Parameters) outputPixels(size PageSize) int64 {
+	xres, yres := float64(p.dpi), float64(p.dpi)
+
+	if p.scaleTo > 0 {
+		xres = 72 * float64(p.scaleTo) / math.Max(size.Width, size.Height)
+		yres = xres
+	} else {
+		if p.scaleToX > 0 {
+			xres = 72 * float64(p.scaleToX) / size.Width
+			if p.scaleToY <= 0 {
+				yres = xres
+			}
+		}
+		if p.scaleToY > 0 {
+			yres = 72 * float64(p.scaleToY) / size.Height
+			if p.scaleToX <= 0 {
+				xres = yres
+			}
+		}
+	}
+
+	// the epsilon absorbs rounding errors of exact scales
+	w := math.Ceil(size.Width*xres/72 - 1e-6)
+	h := math.Ceil(size.Height*yres/72 - 1e-6)
+	return int64(w * h)
+}
+
+// _compressionRatios are typical ratios of the compressed size to the raw
+// size of rendered documents
+var _compressionRatios = map[string]float64{
+	"ppm":  1,
+	"tiff": 1,
+	"png":  0.25,
+	"jpeg": 0.05,
+}
+
+// bytesPerPixel estimates the output bytes of a pixel
+func (p *Parameters) bytesPerPixel() float64 {
+	format, _, _ := parseFormat(p.fmt, p.grayscale)
+
+	channels := 3.0
+	if p.grayscale {
+		channels = 1
+	}
+	return channels * _compressionRatios[format]
+}
+
+var _sniffTokens = [][]byte{
+	[]byte("/JavaScript"),
+	[]byte("/JS"),
+	[]byte("/EmbeddedFile"),
+}
+
+// sniffPDF scans the uncompressed part of a pdf for JavaScript actions and
+// embedded files, objects in compressed object streams are not seen.
+func sniffPDF(pdf string) (javascript, embedded bool, err error) {
+	f, err := os.Open(pdf)
+	if err != nil {
+		return false, false, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	// the tail of the previous chunk is kept so that tokens across chunks
+	// are not missed
+	const overlap = 16
+	r := bufio.NewReader(f)
+	buf := make([]byte, 64*1024)
+	tail := []byte{}
+
+	for {
+		n, err := io.ReadFull(r, buf)
+		chunk := append(tail, buf[:n]...)
+
+		for i, token := range _sniffTokens {
+			if !bytes.Contains(chunk, token) {
+				continue
+			}
+			if i < 2 {
+				javascript = true
+			} else {
+				embedded = true
+			}
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return javascript, embedded, nil
+		}
+		if err != nil {
+			return javascript, embedded, errors.WithStack(err)
+		}
+
+		if len(chunk) > overlap {
+			tail = append([]byte{}, chunk[len(chunk)-overlap:]...)
+		} else {
+			tail = chunk
+		}
+	}
+}
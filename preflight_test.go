@@ -0,0 +1,99 @@
+package pico
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreflight(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+
+	pdf := fakePDF(t, dir, "preflight.pdf", 2, 0,
+		"Encrypted: no",
+		"PDF version: 1.7",
+		"Page    1 size: 612 x 792 pts (letter)",
+		"Page    1 rot:  0",
+		"Page    2 size: 842 x 595 pts (A4)",
+		"Page    2 rot:  90",
+		"<< /S /JavaScript /JS (app.alert(1)) >>",
+	)
+
+	report, err := Preflight(pdf, WithPopplerPath(poppler), WithDpi(72))
+	assert.NoError(t, err)
+	assert.True(t, report.Exists)
+	assert.NotZero(t, report.Size)
+	assert.False(t, report.Encrypted)
+	assert.Equal(t, "1.7", report.PDFVersion)
+	assert.EqualValues(t, 2, report.Pages)
+	assert.Equal(t, []PageSize{{1, 612, 792}, {2, 595, 842}}, report.PageSizes)
+	assert.True(t, report.JavaScript)
+	assert.False(t, report.EmbeddedFiles)
+	assert.EqualValues(t, 612*792+595*842, report.EstimatedPixels)
+	assert.EqualValues(t, 3*report.EstimatedPixels, report.EstimatedBytes)
+
+	// the longest side is scaled to 100 pixels
+	report, err = Preflight(pdf, WithPopplerPath(poppler), WithScaleTo(100),
+		WithFirstPage(1), WithLastPage(1), WithGrayScale())
+	assert.NoError(t, err)
+	assert.Len(t, report.PageSizes, 1)
+	assert.EqualValues(t, 78*100, report.EstimatedPixels)
+	assert.EqualValues(t, 78*100, report.EstimatedBytes)
+
+	// options are validated like those of Convert
+	var argumentError *WrongArgumentError
+	assert.NotPanics(t, func() {
+		_, err = Preflight(pdf, WithPopplerPath(poppler), WithFormat(""))
+	})
+	assert.ErrorAs(t, err, &argumentError)
+	report, err = Preflight(pdf, WithPopplerPath(poppler), WithDpi(-1), WithScaleTo(-100))
+	if assert.ErrorAs(t, err, &argumentError) {
+		assert.Contains(t, err.Error(), "dpi")
+		assert.Contains(t, err.Error(), "scale")
+	}
+	assert.Equal(t, err, report.Err)
+}
+
+func TestPreflightFiles(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+
+	var files []string
+	for i := 0; i < 5; i++ {
+		files = append(files, fakePDF(t, dir, fmt.Sprintf("%d.pdf", i), i+1, 0,
+			"Page size: 612 x 792 pts (letter)", "<< /Type /EmbeddedFile >>"))
+	}
+	locked := fakePDF(t, dir, "locked.pdf", 3, 0, "Password: secret")
+	missing := fmt.Sprintf("%s/missing.pdf", dir)
+
+	reports := PreflightFiles(append(files, locked, missing), WithPopplerPath(poppler), WithJob(3))
+	if !assert.Len(t, reports, 7) {
+		return
+	}
+
+	for i, report := range reports[:5] {
+		assert.Equal(t, files[i], report.File)
+		assert.NoError(t, report.Err)
+		assert.EqualValues(t, i+1, report.Pages)
+		assert.Len(t, report.PageSizes, i+1)
+		assert.True(t, report.EmbeddedFiles)
+	}
+
+	var encryptedError *EncryptedError
+	assert.True(t, reports[5].Locked)
+	assert.ErrorAs(t, reports[5].Err, &encryptedError)
+
+	assert.False(t, reports[6].Exists)
+	assert.True(t, errors.Is(reports[6].Err, os.ErrNotExist))
+
+	var argumentError *WrongArgumentError
+	reports = PreflightFiles(files, WithPopplerPath(poppler), WithFormat("bmp"))
+	if assert.Len(t, reports, 5) {
+		assert.Equal(t, files[0], reports[0].File)
+		assert.ErrorAs(t, reports[0].Err, &argumentError)
+	}
+}
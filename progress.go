@@ -1,7 +1,9 @@
 package pico
 
 import (
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type Observable interface {
@@ -18,10 +20,17 @@ type Observable interface {
 
 	Completed() bool
 	Aborted() bool
+
+	// Stats returns the timing statistics like throughput and ETA
+	Stats() Stats
 }
 
+// _waiting is the filename of a convertor waiting for files
+const _waiting = "<waiting>"
+
 type Progress struct {
-	// pdf is the full path of the pdf file
+	// pdf is the full path of the pdf file, guarded by mu
+	mu  sync.RWMutex
 	pdf string
 
 	// current is the page number currently be converted
@@ -29,12 +38,22 @@ type Progress struct {
 
 	total    int32
 	finished int32
+
+	timing timing
 }
 
 func (p *Progress) Filename() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	return p.pdf
 }
 
+func (p *Progress) setFilename(pdf string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pdf = pdf
+}
+
 func (p *Progress) Total() int32 {
 	return atomic.LoadInt32(&p.total)
 }
@@ -59,8 +78,39 @@ func (p *Progress) Current() int32 {
 	return atomic.LoadInt32(&p.current)
 }
 
+// Stats returns the timing statistics, ETA is estimated by the pages left
+func (p *Progress) Stats() Stats {
+	return merge([]*timing{&p.timing}, int64(p.Total()-p.Finished()))
+}
+
+// observe records the latency of a converted page
+func (p *Progress) observe(d time.Duration) {
+	p.timing.observe(d)
+}
+
+// PageProgress measures the progress of a whole task in pages, regardless of
+// how many files or convertors are involved.
+type PageProgress struct {
+	Progress
+
+	t *Task
+}
+
+func (p *PageProgress) Completed() bool {
+	return p.t.Completed()
+}
+
+func (p *PageProgress) Aborted() bool {
+	return p.t.Aborted()
+}
+
+// Stats returns the timing statistics of the task
+func (p *PageProgress) Stats() Stats {
+	return p.t.Stats()
+}
+
 func (p *Progress) setInit(pdf string, first, last int32) {
-	p.pdf = pdf
+	p.setFilename(pdf)
 
 	atomic.StoreInt32(&p.current, first)
 	atomic.StoreInt32(&p.total, last-first+1)
@@ -68,7 +118,7 @@ func (p *Progress) setInit(pdf string, first, last int32) {
 }
 
 func (p *Progress) setWaiting() {
-	p.pdf = "<waiting>"
+	p.setFilename(_waiting)
 
 	atomic.StoreInt32(&p.current, -1)
 }
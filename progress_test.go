@@ -0,0 +1,73 @@
+package pico
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskPageProgress(t *testing.T) {
+	cases := []struct {
+		kind    string
+		pages   []int
+		options []CallOption
+		// total is the files of a BatchTask or the pages of a SingleTask
+		total int32
+		want  int32
+	}{
+		{"SingleTask", []int{30}, []CallOption{WithJob(4)}, 30, 30},
+		{"BatchTask", []int{12, 30, 9}, []CallOption{WithPageRange(2, 10), WithPreflight()}, 3, 9 + 9 + 8},
+	}
+
+	for _, c := range cases {
+		t.Run(c.kind, func(t *testing.T) {
+			task, err := converts[c.kind](fakeFiles(t, 0, c.pages...), fakeOptions(t, c.options...)...)
+			assert.NoError(t, err, "conversion task initialization should not failed")
+			task.Wait()
+
+			assert.EqualValues(t, c.total, task.Total())
+			assert.EqualValues(t, c.total, task.Finished())
+			assert.EqualValues(t, c.want, task.Pages.Total())
+			assert.EqualValues(t, c.want, task.Pages.Finished())
+		})
+	}
+}
+
+func TestPreflightChannel(t *testing.T) {
+	files := fakeFiles(t, 0, 3, 2)
+
+	cases := []struct {
+		name    string
+		files   []string
+		options []CallOption
+		state   State
+		pages   int32
+	}{
+		// the task starts before the channel is fed
+		{"fed", files, nil, StateCompleted, 5},
+		// the preflight stops once the task times out
+		{"timeout", nil, []CallOption{WithTimeout(100 * time.Millisecond)}, StateCancelled, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ch := make(chan string)
+			task, err := ConvertFiles(ch, fakeOptions(t, append(c.options, WithPreflight())...)...)
+			assert.NoError(t, err, "conversion task initialization should not failed")
+			assert.Equal(t, StatePreflight, task.State())
+
+			if c.files != nil {
+				for _, pdf := range c.files {
+					ch <- pdf
+				}
+				close(ch)
+			}
+			task.Wait()
+
+			assert.Equal(t, c.state, task.State())
+			assert.EqualValues(t, c.pages, task.Pages.Total())
+			assert.EqualValues(t, c.pages, task.Pages.Finished())
+		})
+	}
+}
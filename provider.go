@@ -1,8 +1,14 @@
 package pico
 
 import (
+	"bufio"
+	"bytes"
+	"io"
 	"os"
 	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
 )
 
 const _dirwalkchansize = 100
@@ -10,9 +16,19 @@ const _dirwalkchansize = 100
 type PdfProvider interface {
 	Source() <-chan string
 	Count() int
+
+	// Err returns the errors occurred while enumerating the files, it is
+	// complete once `Source()` is drained or the provider is closed
+	Err() error
+
+	// Close stops the enumeration of an asynchronous provider, closing a
+	// closed provider returns ErrProviderClosed
+	Close() error
 }
 
 type ChanProvider struct {
+	providerState
+
 	source chan string
 }
 
@@ -31,66 +47,191 @@ func (p *ChanProvider) Count() int {
 }
 
 func FromSlice(files []string) PdfProvider {
+	return newSliceFileProvider(files)
+}
+
+func newSliceFileProvider(files []string) *SliceFileProvider {
 	source := make(chan string, len(files))
 	defer close(source)
 	for _, file := range files {
 		source <- file
 	}
 	return &SliceFileProvider{
-		ChanProvider{source},
+		ChanProvider{source: source},
 		len(files),
 	}
 }
 
+// newAsyncProvider returns a provider fed by a goroutine through `send`
+func newAsyncProvider() *ChanProvider {
+	return &ChanProvider{source: make(chan string, _dirwalkchansize)}
+}
+
+// send provides the file, false is returned if the provider is closed
+func (p *ChanProvider) send(pdf string) bool {
+	select {
+	case p.source <- pdf:
+		return true
+	case <-p.Done():
+		return false
+	}
+}
+
 func (p *SliceFileProvider) Count() int {
 	return p.len
 }
 
 func FromGlob(pattern string) PdfProvider {
-	files, _ := filepath.Glob(pattern)
+	files, err := filepath.Glob(pattern)
 
-	return FromSlice(files)
+	p := newSliceFileProvider(files)
+	if err != nil {
+		p.error(errors.Wrapf(err, "invalid pattern %q", pattern))
+	}
+	return p
 }
 
 func FromChan(ch chan string) PdfProvider {
 	return &ChanProvider{source: ch}
 }
 
+// FromMultiSource provides the files matching the patterns, a directory
+// provides the pdf files in it. Paths that do not exist are provided as is,
+// thus the conversion reports them.
 func FromMultiSource(patterns []string) PdfProvider {
 	files := []string{}
 	for _, pattern := range patterns {
-		info, _ := os.Stat(pattern)
-
-		if info.IsDir() {
-			pattern = filepath.Join(pattern, "/*.pdf")
-		}
-
-		batch, _ := filepath.Glob(pattern)
-		files = append(files, batch...)
+		files = append(files, expandSource(pattern)...)
 	}
 
 	return FromSlice(files)
 }
 
+// FromMultiSourceAsync is like FromMultiSource but expands the patterns
+// asynchronously
 func FromMultiSourceAsync(patterns []string) PdfProvider {
-	ch := make(chan string, _dirwalkchansize)
+	p := newAsyncProvider()
 	go func() {
-		defer close(ch)
+		defer close(p.source)
 		for _, pattern := range patterns {
-			info, _ := os.Stat(pattern)
+			for _, file := range expandSource(pattern) {
+				if !p.send(file) {
+					return
+				}
+			}
+		}
+	}()
+
+	return p
+}
 
-			if info.IsDir() {
-				pattern = filepath.Join(pattern, "/*")
+func expandSource(pattern string) []string {
+	info, err := os.Stat(pattern)
+	switch {
+	case err == nil && info.IsDir():
+		pattern = filepath.Join(pattern, "*.pdf")
+	case err == nil, !hasMeta(pattern):
+		return []string{pattern}
+	}
+
+	batch, _ := filepath.Glob(pattern)
+	return batch
+}
+
+// providerState implements the error reporting and closing of providers
+type providerState struct {
+	mu     sync.Mutex
+	errs   []error
+	closed bool
+	done   chan struct{}
+}
+
+// Errors returns the errors occurred so far
+func (s *providerState) Errors() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]error{}, s.errs...)
+}
+
+// Err returns nil, the only error, or a *MultiError of all the errors
+func (s *providerState) Err() error {
+	switch errs := s.Errors(); len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return &MultiError{Errors: errs}
+	}
+}
+
+func (s *providerState) error(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = append(s.errs, err)
+}
+
+// Done is closed when the provider is closed
+func (s *providerState) Done() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done == nil {
+		s.done = make(chan struct{})
+	}
+	return s.done
+}
+
+func (s *providerState) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.WithStack(ErrProviderClosed)
+	}
+	s.closed = true
+	if s.done == nil {
+		s.done = make(chan struct{})
+	}
+	close(s.done)
+	return nil
+}
+
+// ReaderProvider provides the paths read from a reader
+type ReaderProvider struct {
+	*ChanProvider
+}
+
+// FromReader provides the paths read from `r` asynchronously, paths are
+// separated by `delim` which is usually '\n' or 0 (like `find -print0`).
+// Empty paths are skipped, so is the '\r' before a newline. The read error is
+// reported by `Err()`.
+func FromReader(r io.Reader, delim byte) *ReaderProvider {
+	p := &ReaderProvider{newAsyncProvider()}
+
+	go func() {
+		defer close(p.source)
+
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadBytes(delim)
+			line = bytes.TrimSuffix(line, []byte{delim})
+			if delim == '\n' {
+				line = bytes.TrimSuffix(line, []byte{'\r'})
+			}
+			if len(line) > 0 && !p.send(string(line)) {
+				return
 			}
 
-			batch, _ := filepath.Glob(pattern)
-			for _, file := range batch {
-				ch <- file
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				p.error(errors.Wrap(err, "failed to read the file list"))
+				return
 			}
 		}
 	}()
 
-	return FromChan(ch)
+	return p
 }
 
 func FromInterface(i interface{}) PdfProvider {
@@ -103,6 +244,10 @@ func FromInterface(i interface{}) PdfProvider {
 		return FromSlice(i)
 	case chan string:
 		return FromChan(i)
+	case []Item:
+		return FromItems(i)
+	case chan Item:
+		return FromItemChan(i)
 	}
 	panic("unsupported type")
 }
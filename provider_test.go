@@ -0,0 +1,112 @@
+package pico
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingReader struct {
+	r io.Reader
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err == io.EOF {
+		return n, fmt.Errorf("connection reset")
+	}
+	return n, err
+}
+
+func TestReaderProvider(t *testing.T) {
+	p := FromReader(strings.NewReader("a.pdf\r\n\nb c.pdf\nd.pdf"), '\n')
+	assert.Equal(t, []string{"a.pdf", "b c.pdf", "d.pdf"}, collect(p))
+	assert.Equal(t, -1, p.Count())
+	assert.Empty(t, p.Errors())
+
+	p = FromReader(strings.NewReader("a\nb.pdf\x00\x00c.pdf\x00"), 0)
+	assert.Equal(t, []string{"a\nb.pdf", "c.pdf"}, collect(p))
+
+	p = FromReader(&failingReader{strings.NewReader("a.pdf\nb.pdf")}, '\n')
+	assert.Equal(t, []string{"a.pdf", "b.pdf"}, collect(p))
+	if assert.Len(t, p.Errors(), 1) {
+		assert.Contains(t, p.Errors()[0].Error(), "connection reset")
+	}
+}
+
+func TestReaderProviderTotals(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+
+	list := ""
+	for i := 0; i < 4; i++ {
+		list += fakePDF(t, dir, fmt.Sprintf("%d.pdf", i), i+1, 0) + "\n"
+	}
+
+	task, err := ConvertFiles(FromReader(strings.NewReader(list), '\n'),
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+		WithJob(2),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+	task.Wait()
+
+	assert.NoError(t, task.Error())
+	assert.EqualValues(t, 4, task.Total())
+	assert.EqualValues(t, 4, task.Finished())
+	assert.EqualValues(t, 10, task.Pages.Total())
+	assert.EqualValues(t, 10, task.Pages.Finished())
+}
+
+func TestProviderClose(t *testing.T) {
+	// the reader never ends, the provider stops once it is closed
+	r, w := io.Pipe()
+	defer w.Close()
+	go func() {
+		for {
+			if _, err := w.Write([]byte("a.pdf\n")); err != nil {
+				return
+			}
+		}
+	}()
+
+	p := FromReader(r, '\n')
+	assert.Equal(t, "a.pdf", <-p.Source())
+	assert.NoError(t, p.Close())
+	assert.True(t, errors.Is(p.Close(), ErrProviderClosed))
+	for range p.Source() {
+	}
+
+	glob := FromGlob("[")
+	assert.Empty(t, collect(glob))
+	assert.Error(t, glob.Err())
+}
+
+func TestProviderErrors(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+	a := fakePDF(t, dir, "a.pdf", 2, 0)
+	missing := filepath.Join(dir, "missing.pdf")
+
+	task, err := ConvertFiles(FromWalk([]string{a, missing}),
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+	task.Wait()
+
+	errs := task.Errors()
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "", errs[0].File())
+		assert.True(t, errors.Is(errs[0], os.ErrNotExist))
+		assert.Contains(t, errs[0].Error(), "failed to provide files")
+	}
+	assert.EqualValues(t, 1, task.Finished())
+	assert.Equal(t, StateFailed, task.State())
+}
@@ -0,0 +1,138 @@
+package pico
+
+import (
+	"sort"
+	"sync"
+)
+
+// FileReport is the conversion result of a file
+type FileReport struct {
+	File string
+
+	// Succeeded are the pages converted
+	Succeeded []int32
+
+	// Skipped are the pages requested but not converted, e.g. due to errors
+	// or cancellation
+	Skipped []int32
+
+	// Errors maps page to the errors occurred on that page, errors not
+	// related to a specific page are under -1
+	Errors map[int32][]*ConversionError
+}
+
+// Failed reports whether any error occurred on the file
+func (r *FileReport) Failed() bool {
+	return len(r.Errors) > 0
+}
+
+// Report maps files to their conversion results
+type Report struct {
+	Files map[string]*FileReport
+
+	// Errors are the errors not related to any file, e.g. the cancellation
+	// of an idle convertor
+	Errors []*ConversionError
+}
+
+// fileRecord tracks the requested page ranges and converted pages of a file
+type fileRecord struct {
+	ranges    [][2]int32
+	succeeded map[int32]bool
+}
+
+// pageRecorder tracks the pages of all the files of a task
+type pageRecorder struct {
+	mu    sync.Mutex
+	files map[string]*fileRecord
+}
+
+func (r *pageRecorder) get(pdf string) *fileRecord {
+	if r.files == nil {
+		r.files = map[string]*fileRecord{}
+	}
+	rec, ok := r.files[pdf]
+	if !ok {
+		rec = &fileRecord{succeeded: map[int32]bool{}}
+		r.files[pdf] = rec
+	}
+	return rec
+}
+
+func (r *pageRecorder) addRange(pdf string, first, last int32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec := r.get(pdf)
+	rec.ranges = append(rec.ranges, [2]int32{first, last})
+}
+
+func (r *pageRecorder) addPage(pdf string, page int32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.get(pdf).succeeded[page] = true
+}
+
+func (r *pageRecorder) report(errs []*ConversionError) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := &Report{Files: map[string]*FileReport{}}
+	fileReport := func(pdf string) *FileReport {
+		fr, ok := report.Files[pdf]
+		if !ok {
+			fr = &FileReport{File: pdf, Errors: map[int32][]*ConversionError{}}
+			report.Files[pdf] = fr
+		}
+		return fr
+	}
+
+	for pdf, rec := range r.files {
+		fr := fileReport(pdf)
+
+		requested := map[int32]bool{}
+		for _, rg := range rec.ranges {
+			for page := rg[0]; page <= rg[1]; page++ {
+				requested[page] = true
+			}
+		}
+
+		for page := range rec.succeeded {
+			fr.Succeeded = append(fr.Succeeded, page)
+		}
+		for page := range requested {
+			if !rec.succeeded[page] {
+				fr.Skipped = append(fr.Skipped, page)
+			}
+		}
+
+		sortPages(fr.Succeeded)
+		sortPages(fr.Skipped)
+	}
+
+	for _, err := range errs {
+		if err.pdf == "" || err.pdf == _waiting {
+			report.Errors = append(report.Errors, err)
+			continue
+		}
+
+		fr := fileReport(err.pdf)
+		page := err.page
+		if page < 0 {
+			page = -1
+		}
+		fr.Errors[page] = append(fr.Errors[page], err)
+	}
+
+	return report
+}
+
+func sortPages(pages []int32) {
+	sort.Slice(pages, func(i, j int) bool { return pages[i] < pages[j] })
+}
+
+// Report maps every file to its converted pages, skipped pages and errors by
+// page. It waits for the task to complete.
+func (t *Task) Report() *Report {
+	<-t.done
+	return t.records.report(t.errors())
+}
@@ -0,0 +1,182 @@
+package pico
+
+import (
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// State is the lifecycle state of a task or a convertor
+type State int32
+
+const (
+	// StatePending means the task (or convertor) is created but not started
+	StatePending State = iota
+
+	// StatePreflight means the task is counting pages of the files
+	StatePreflight
+
+	// StateRunning means the conversion is in progress
+	StateRunning
+
+	// StateDraining means no more page will be converted, the remaining
+	// entries are being delivered and the resources released
+	StateDraining
+
+	// StateCompleted means the conversion finished without any error
+	StateCompleted
+
+	// StateFailed means the conversion finished (or failed to start) with
+	// errors, the errors are reported by `Errors()`
+	StateFailed
+
+	// StateCancelled means the conversion was stopped by the cancellation
+	// or the timeout of the context
+	StateCancelled
+)
+
+var _stateNames = []string{
+	StatePending:   "pending",
+	StatePreflight: "preflight",
+	StateRunning:   "running",
+	StateDraining:  "draining",
+	StateCompleted: "completed",
+	StateFailed:    "failed",
+	StateCancelled: "cancelled",
+}
+
+func (s State) String() string {
+	if s >= 0 && int(s) < len(_stateNames) {
+		return _stateNames[s]
+	}
+	return "unknown"
+}
+
+// Terminal reports whether no more transition could happen from the state
+func (s State) Terminal() bool {
+	return s == StateCompleted || s == StateFailed || s == StateCancelled
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *State) UnmarshalText(text []byte) error {
+	for i, name := range _stateNames {
+		if name == string(text) {
+			*s = State(i)
+			return nil
+		}
+	}
+	return errors.Errorf("unknown state %q", text)
+}
+
+// _transitions lists the valid next states of every non-terminal state
+var _transitions = map[State][]State{
+	StatePending:   {StatePreflight, StateRunning, StateFailed, StateCancelled},
+	StatePreflight: {StateRunning, StateFailed, StateCancelled},
+	StateRunning:   {StateDraining, StateFailed, StateCancelled},
+	StateDraining:  {StateCompleted, StateFailed, StateCancelled},
+}
+
+func canTransition(from, to State) bool {
+	for _, next := range _transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// lifecycle holds a State which could only be changed by valid transitions,
+// it is safe for concurrent use.
+type lifecycle struct {
+	state int32
+}
+
+// State returns the current lifecycle state
+func (l *lifecycle) State() State {
+	return State(atomic.LoadInt32(&l.state))
+}
+
+// transition moves to state `to`, an error is returned and the state is left
+// unchanged if the transition is not valid from current state.
+func (l *lifecycle) transition(to State) error {
+	for {
+		from := l.State()
+		if !canTransition(from, to) {
+			return errors.Errorf("invalid state transition from %s to %s", from, to)
+		}
+		if atomic.CompareAndSwapInt32(&l.state, int32(from), int32(to)) {
+			return nil
+		}
+	}
+}
+
+// WorkerStatus is a snapshot of a convertor
+type WorkerStatus struct {
+	Id       int32
+	State    State
+	File     string
+	Current  int32
+	Total    int32
+	Finished int32
+	Errors   []string
+	Stats    Stats
+}
+
+// Status is a snapshot of a task and all its convertors, it could be
+// serialised by encoding/json. For a BatchTask, Total and Finished count
+// files while TotalPages and FinishedPages count pages.
+type Status struct {
+	Id            uint64
+	Kind          string
+	State         State
+	Total         int32
+	Finished      int32
+	TotalPages    int32
+	FinishedPages int32
+	BytesWritten  int64
+	Errors        []string
+	Stats         Stats
+	Workers       []WorkerStatus
+}
+
+// Status returns a snapshot of the convertor
+func (c *Convertor) Status() WorkerStatus {
+	s := WorkerStatus{
+		Id:       c.id,
+		State:    c.State(),
+		File:     c.Filename(),
+		Current:  c.Current(),
+		Total:    c.Total(),
+		Finished: c.Finished(),
+		Stats:    c.Stats(),
+	}
+	for _, err := range c.Errors() {
+		s.Errors = append(s.Errors, err.Error())
+	}
+	return s
+}
+
+// Status returns a snapshot of the task and all its convertors, it is safe
+// to be called at any time after the task is started.
+func (t *Task) Status() Status {
+	s := Status{
+		Id:            t.id,
+		Kind:          t.kind,
+		State:         t.State(),
+		Total:         t.Total(),
+		Finished:      t.Finished(),
+		TotalPages:    t.Pages.Total(),
+		FinishedPages: t.Pages.Finished(),
+		BytesWritten:  t.BytesWritten(),
+		Stats:         t.Stats(),
+	}
+	for _, c := range t.Convertors {
+		w := c.Status()
+		s.Errors = append(s.Errors, w.Errors...)
+		s.Workers = append(s.Workers, w)
+	}
+	return s
+}
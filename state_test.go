@@ -0,0 +1,109 @@
+package pico
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateTransitions(t *testing.T) {
+	var l lifecycle
+	assert.Equal(t, StatePending, l.State())
+
+	assert.NoError(t, l.transition(StatePreflight))
+	assert.NoError(t, l.transition(StateRunning))
+	assert.Error(t, l.transition(StatePreflight), "preflight is only allowed before running")
+	assert.Error(t, l.transition(StateCompleted), "a running task must drain before completed")
+	assert.NoError(t, l.transition(StateDraining))
+	assert.NoError(t, l.transition(StateCompleted))
+	assert.True(t, l.State().Terminal())
+
+	for _, s := range []State{StatePending, StateRunning, StateFailed, StateCancelled} {
+		assert.Error(t, l.transition(s), "no transition from a terminal state")
+	}
+	assert.Equal(t, StateCompleted, l.State())
+
+	b, err := json.Marshal(StateCancelled)
+	assert.NoError(t, err)
+	assert.Equal(t, `"cancelled"`, string(b))
+
+	var s State
+	assert.NoError(t, json.Unmarshal(b, &s))
+	assert.Equal(t, StateCancelled, s)
+	assert.Error(t, json.Unmarshal([]byte(`"sleeping"`), &s))
+}
+
+func TestTaskStatus(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+
+	var files []string
+	for i := 0; i < 3; i++ {
+		files = append(files, fakePDF(t, dir, fmt.Sprintf("%d.pdf", i), 4, 0.01))
+	}
+
+	task, err := ConvertFiles(files,
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+		WithPreflight(),
+		WithJob(2),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+	assert.Contains(t, []State{StatePreflight, StateRunning, StateDraining, StateCompleted}, task.State())
+	task.Wait()
+
+	status := task.Status()
+	assert.Equal(t, StateCompleted, status.State)
+	assert.Equal(t, KindBatch, status.Kind)
+	assert.EqualValues(t, 3, status.Finished)
+	assert.EqualValues(t, 12, status.FinishedPages)
+	assert.Empty(t, status.Errors)
+	if assert.Len(t, status.Workers, 2) {
+		for _, w := range status.Workers {
+			assert.Equal(t, StateCompleted, w.State)
+		}
+	}
+
+	b, err := json.Marshal(status)
+	assert.NoError(t, err)
+
+	var decoded Status
+	assert.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, status.State, decoded.State)
+	assert.Equal(t, status.Workers[1].State, decoded.Workers[1].State)
+
+	// errors make the task failed
+	broken, err := Convert(fakePDF(t, dir, "broken.pdf", 3, 0, "Stderr: 2 Syntax Error (1): Illegal character"),
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+	broken.Wait()
+	assert.Equal(t, StateFailed, broken.State())
+	assert.Len(t, broken.Status().Errors, 1)
+
+	// cancellation makes the task cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	slow, err := Convert(fakePDF(t, dir, "slow.pdf", 20, 0.05),
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+		WithContext(ctx),
+		WithJob(2),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, StateRunning, slow.Status().State)
+	cancel()
+	slow.Wait()
+
+	status = slow.Status()
+	assert.Equal(t, StateCancelled, status.State)
+	assert.True(t, slow.Aborted())
+	for _, w := range status.Workers {
+		assert.Equal(t, StateCancelled, w.State)
+	}
+}
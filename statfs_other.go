@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package pico
+
+// statfs is not supported on this platform, the free space check is skipped
+func statfs(dir string) (int64, bool) {
+	return 0, false
+}
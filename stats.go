@@ -0,0 +1,181 @@
+package pico
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// _latencySamples is the max number of recent page latencies kept for
+// percentile calculation
+const _latencySamples = 1024
+
+// _ewmaWeight is the weight of the newest sample in the moving average
+const _ewmaWeight = 0.2
+
+// Stats is a snapshot of the timing statistics of a convertor or a task
+type Stats struct {
+	StartedAt time.Time
+	Elapsed   time.Duration
+
+	// Pages is the number of pages converted
+	Pages int64
+
+	// Throughput is the moving average of converted pages per second
+	Throughput float64
+
+	// ETA is the estimated remaining time, it is zero when unknown
+	ETA time.Duration
+
+	// page latency statistics, percentiles are calculated over the most
+	// recent pages
+	MinLatency  time.Duration
+	MaxLatency  time.Duration
+	MeanLatency time.Duration
+	P50Latency  time.Duration
+	P90Latency  time.Duration
+	P99Latency  time.Duration
+}
+
+// timing records the page latencies of a convertor
+type timing struct {
+	mu sync.Mutex
+
+	startedAt time.Time
+	stoppedAt time.Time
+
+	// samples is a ring buffer of recent latencies, next is where the next
+	// sample goes
+	samples []time.Duration
+	next    int
+
+	count int64
+	sum   time.Duration
+	min   time.Duration
+	max   time.Duration
+	ewma  float64
+}
+
+func (t *timing) start(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.startedAt.IsZero() {
+		t.startedAt = now
+	}
+}
+
+func (t *timing) stop(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stoppedAt.IsZero() {
+		t.stoppedAt = now
+	}
+}
+
+func (t *timing) observe(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.samples) < _latencySamples {
+		t.samples = append(t.samples, d)
+	} else {
+		t.samples[t.next] = d
+	}
+	t.next = (t.next + 1) % _latencySamples
+
+	if t.count == 0 || d < t.min {
+		t.min = d
+	}
+	if d > t.max {
+		t.max = d
+	}
+
+	if t.count == 0 {
+		t.ewma = float64(d)
+	} else {
+		t.ewma = _ewmaWeight*float64(d) + (1-_ewmaWeight)*t.ewma
+	}
+
+	t.count++
+	t.sum += d
+}
+
+func (t *timing) elapsed() time.Duration {
+	switch {
+	case t.startedAt.IsZero():
+		return 0
+	case t.stoppedAt.IsZero():
+		return time.Since(t.startedAt)
+	default:
+		return t.stoppedAt.Sub(t.startedAt)
+	}
+}
+
+// throughput is pages per second derived from the average latency, a stopped
+// timing has no throughput
+func (t *timing) throughput() float64 {
+	if t.ewma <= 0 || !t.stoppedAt.IsZero() {
+		return 0
+	}
+	return float64(time.Second) / t.ewma
+}
+
+// merge aggregates timings into a Stats, `remaining` is the number of pages
+// waiting for conversion used to estimate ETA.
+func merge(timings []*timing, remaining int64) (s Stats) {
+	var samples []time.Duration
+	var sum time.Duration
+
+	for _, t := range timings {
+		t.mu.Lock()
+
+		if !t.startedAt.IsZero() && (s.StartedAt.IsZero() || t.startedAt.Before(s.StartedAt)) {
+			s.StartedAt = t.startedAt
+		}
+		if e := t.elapsed(); e > s.Elapsed {
+			s.Elapsed = e
+		}
+
+		if t.count > 0 {
+			if s.Pages == 0 || t.min < s.MinLatency {
+				s.MinLatency = t.min
+			}
+			if t.max > s.MaxLatency {
+				s.MaxLatency = t.max
+			}
+		}
+
+		s.Pages += t.count
+		s.Throughput += t.throughput()
+		sum += t.sum
+		samples = append(samples, t.samples...)
+
+		t.mu.Unlock()
+	}
+
+	if s.Pages > 0 {
+		s.MeanLatency = sum / time.Duration(s.Pages)
+	}
+
+	if len(samples) > 0 {
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+		s.P50Latency = percentile(samples, 50)
+		s.P90Latency = percentile(samples, 90)
+		s.P99Latency = percentile(samples, 99)
+	}
+
+	if s.Throughput > 0 && remaining > 0 {
+		s.ETA = time.Duration(float64(remaining) / s.Throughput * float64(time.Second))
+	}
+
+	return
+}
+
+// percentile picks the p-th percentile from sorted samples
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
@@ -0,0 +1,46 @@
+package pico
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	cases := []struct {
+		kind  string
+		pages []int
+		// perWorker is the pages converted by every worker, zero if it
+		// depends on scheduling
+		perWorker int32
+	}{
+		{"SingleTask", []int{12}, 6},
+		{"BatchTask", []int{5, 7}, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.kind, func(t *testing.T) {
+			task, err := converts[c.kind](fakeFiles(t, 0.01, c.pages...), fakeOptions(t, WithJob(2))...)
+			assert.NoError(t, err, "conversion task initialization should not failed")
+			task.Wait()
+
+			stats := task.Stats()
+			assert.EqualValues(t, 12, stats.Pages)
+			assert.NotZero(t, stats.Elapsed)
+			assert.NotZero(t, stats.MinLatency)
+			assert.True(t, stats.MinLatency <= stats.P50Latency)
+			assert.True(t, stats.P50Latency <= stats.P99Latency)
+			assert.True(t, stats.P99Latency <= stats.MaxLatency)
+			assert.Zero(t, stats.ETA, "completed task should have nothing left")
+
+			var pages int64
+			for _, convertor := range task.Convertors {
+				if c.perWorker > 0 {
+					assert.EqualValues(t, c.perWorker, convertor.Stats().Pages)
+				}
+				pages += convertor.Stats().Pages
+			}
+			assert.EqualValues(t, 12, pages)
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package pico
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// _submissionQueueSize is how many submitted files could wait for a
+// convertor before `Submit` blocks
+const _submissionQueueSize = 100
+
+// Submission is a file submitted to a BatchTask opened by `OpenBatch`, its
+// progress is measured by pages.
+type Submission struct {
+	Progress
+
+	// Entries is the channel of conversion progress entry of the file, the
+	// format is the same as `Task.Entries`. It is closed once the file is
+	// done, and like `Task.Entries` it must be drained or `Wait()` must be
+	// called, otherwise the convertor blocks.
+	Entries chan []string
+
+	mu      sync.Mutex
+	errs    []*ConversionError
+	aborted bool
+
+	done chan interface{}
+}
+
+func newSubmission(pdf string) *Submission {
+	s := &Submission{
+		Entries: make(chan []string, 200),
+		done:    make(chan interface{}),
+	}
+	s.setFilename(pdf)
+	return s
+}
+
+// Done is closed when the file is done
+func (s *Submission) Done() <-chan interface{} {
+	return s.done
+}
+
+func (s *Submission) Completed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Aborted reports whether the file was stopped by cancellation, or never
+// converted because the task was cancelled
+func (s *Submission) Aborted() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.aborted
+}
+
+// Wait drains the entries and waits for the file to be done
+func (s *Submission) Wait() {
+	for range s.Entries {
+	}
+	<-s.done
+}
+
+// Errors returns the errors of the file, it waits for the file to be done
+func (s *Submission) Errors() []*ConversionError {
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*ConversionError{}, s.errs...)
+}
+
+// Error returns a *MultiError of all the errors of the file, or nil if there
+// is none
+func (s *Submission) Error() error {
+	if errs := s.Errors(); len(errs) > 0 {
+		return newMultiError(errs...)
+	}
+	return nil
+}
+
+func (s *Submission) receiveError(err *ConversionError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = append(s.errs, err)
+}
+
+// finish marks the file as done, it is called exactly once
+func (s *Submission) finish(aborted bool) {
+	s.mu.Lock()
+	s.aborted = aborted
+	s.mu.Unlock()
+
+	s.timing.stop(time.Now())
+	close(s.Entries)
+	close(s.done)
+}
+
+// submissionQueue is the provider of a task opened by `OpenBatch`
+type submissionQueue struct {
+	ChanItemProvider
+
+	ctx context.Context
+
+	// lock guards closing the items against the submissions being sent
+	lock    sync.RWMutex
+	closing bool
+}
+
+func newSubmissionQueue(ctx context.Context) *submissionQueue {
+	q := &submissionQueue{ctx: ctx}
+	q.items = make(chan Item, _submissionQueueSize)
+	return q
+}
+
+// submit queues the file, it blocks while the queue is full
+func (q *submissionQueue) submit(file string, options []CallOption) (*Submission, error) {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+
+	if q.closing {
+		return nil, errors.WithStack(ErrSubmissionsClosed)
+	}
+	if err := q.ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	s := newSubmission(file)
+	select {
+	case q.items <- Item{File: file, Options: options, submission: s}:
+		return s, nil
+	case <-q.ctx.Done():
+		return nil, errors.WithStack(q.ctx.Err())
+	}
+}
+
+// closeSubmissions stops accepting files, the queued files are still
+// converted
+func (q *submissionQueue) closeSubmissions() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if !q.closing {
+		q.closing = true
+		close(q.items)
+	}
+}
+
+// Close stops accepting files and aborts the files left in the queue, which
+// happens only if the task is cancelled
+func (q *submissionQueue) Close() error {
+	q.closeSubmissions()
+
+	err := q.ctx.Err()
+	if err == nil {
+		err = ErrSubmissionsClosed
+	}
+	for item := range q.items {
+		s := item.submission
+		s.receiveError(&ConversionError{pdf: item.File, page: -1, workerId: -1, err: errors.WithStack(err)})
+		s.finish(true)
+	}
+	return q.ChanItemProvider.Close()
+}
+
+// Submit queues a file for conversion, the options are applied on top of
+// those of the task like the options of an `Item`. It blocks while the queue
+// is full. ErrSubmissionsClosed is returned if the task is not opened by
+// `OpenBatch` or `CloseSubmissions()` has been called.
+func (t *BatchTask) Submit(file string, options ...CallOption) (*Submission, error) {
+	q, ok := t.provider.(*submissionQueue)
+	if !ok {
+		return nil, errors.WithStack(ErrSubmissionsClosed)
+	}
+	return q.submit(file, options)
+}
+
+// CloseSubmissions stops accepting files, the task finishes once the
+// submitted files are done. It could be called more than once.
+func (t *BatchTask) CloseSubmissions() {
+	if q, ok := t.provider.(*submissionQueue); ok {
+		q.closeSubmissions()
+	}
+}
@@ -0,0 +1,107 @@
+package pico
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmit(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+	a := fakePDF(t, dir, "a.pdf", 2, 0)
+	b := fakePDF(t, dir, "b.pdf", 5, 0)
+
+	task, err := OpenBatch(
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+		WithJob(2),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+
+	sa, err := task.Submit(a)
+	assert.NoError(t, err)
+	sb, err := task.Submit(b, WithPageRange(2, 4))
+	assert.NoError(t, err)
+	missing, err := task.Submit(filepath.Join(dir, "missing.pdf"))
+	assert.NoError(t, err)
+
+	pages := []string{}
+	for entry := range sb.Entries {
+		pages = append(pages, entry[0])
+	}
+	assert.Equal(t, []string{"2", "3", "4"}, pages)
+	assert.NoError(t, sb.Error())
+	assert.EqualValues(t, 3, sb.Total())
+	assert.EqualValues(t, 3, sb.Finished())
+
+	sa.Wait()
+	assert.NoError(t, sa.Error())
+	assert.EqualValues(t, 2, sa.Finished())
+	assert.True(t, sa.Completed())
+
+	missing.Wait()
+	assert.Len(t, missing.Errors(), 1)
+	assert.False(t, missing.Aborted())
+
+	// the task keeps running until the submissions are closed
+	assert.False(t, task.Completed())
+	task.CloseSubmissions()
+	task.CloseSubmissions()
+	task.Wait()
+
+	_, err = task.Submit(a)
+	assert.True(t, errors.Is(err, ErrSubmissionsClosed))
+	assert.EqualValues(t, 3, task.Finished())
+	assert.EqualValues(t, 5, task.Pages.Finished())
+	assert.Len(t, task.Errors(), 1)
+	assert.Equal(t, StateFailed, task.State())
+}
+
+func TestSubmitCancel(t *testing.T) {
+	poppler := fakePoppler(t)
+	dir := t.TempDir()
+	slow := fakePDF(t, dir, "slow.pdf", 3, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	task, err := OpenBatch(
+		WithPopplerPath(poppler),
+		WithOutputFolder(t.TempDir()),
+		WithJob(1),
+		WithContext(ctx),
+	)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+
+	submissions := []*Submission{}
+	for i := 0; i < 3; i++ {
+		s, err := task.Submit(slow)
+		assert.NoError(t, err)
+		submissions = append(submissions, s)
+	}
+
+	<-submissions[0].Entries
+	cancel()
+	task.Wait()
+
+	for _, s := range submissions {
+		s.Wait()
+		assert.True(t, s.Aborted())
+		assert.True(t, errors.Is(s.Error(), context.Canceled))
+	}
+	assert.Equal(t, StateCancelled, task.State())
+
+	_, err = task.Submit(slow)
+	assert.Error(t, err)
+}
+
+func TestSubmitNotOpened(t *testing.T) {
+	task, err := ConvertFiles([]string{}, WithPopplerPath(fakePoppler(t)), WithOutputFolder(t.TempDir()))
+	assert.NoError(t, err)
+	task.Wait()
+
+	_, err = task.Submit("a.pdf")
+	assert.True(t, errors.Is(err, ErrSubmissionsClosed))
+}
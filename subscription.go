@@ -0,0 +1,228 @@
+package pico
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type EventKind int
+
+const (
+	EventFileStart EventKind = iota
+	EventPageDone
+	EventFileDone
+	EventError
+	EventTaskDone
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventFileStart:
+		return "file-start"
+	case EventPageDone:
+		return "page-done"
+	case EventFileDone:
+		return "file-done"
+	case EventError:
+		return "error"
+	case EventTaskDone:
+		return "task-done"
+	}
+	return "unknown"
+}
+
+// Event is what subscribers receive, only the field matches the `Kind` is set
+type Event struct {
+	Kind EventKind
+	Time time.Time
+
+	File  *FileEvent
+	Page  *PageEvent
+	Error *ConversionError
+	Task  *TaskEvent
+}
+
+// Policy decides what happens when a subscriber does not keep up with events
+type Policy int
+
+const (
+	// PolicyBlock blocks the convertors until the subscriber has room for the
+	// event, this is how `Entries` behaves
+	PolicyBlock Policy = iota
+
+	// PolicyDropOldest discards the oldest buffered event to make room for
+	// the new one, so that a slow subscriber never stalls the conversion
+	PolicyDropOldest
+
+	// PolicyUnbounded buffers as many events as needed, the buffer grows
+	// without limit if the subscriber never reads
+	PolicyUnbounded
+)
+
+// Subscription is an independent stream of task events, events are delivered
+// through `C` which is closed after the EventTaskDone event or when the
+// subscription is cancelled.
+type Subscription struct {
+	// dropped must be the first field to keep it 64-bit aligned
+	dropped uint64
+
+	C <-chan Event
+
+	policy Policy
+	b      *broker
+
+	// ch is the channel the events are published to, for PolicyUnbounded it
+	// is read by `pump` instead of the subscriber
+	ch   chan Event
+	quit chan interface{}
+
+	// mu serializes publishing under PolicyDropOldest
+	mu sync.Mutex
+
+	quitOnce  sync.Once
+	closeOnce sync.Once
+}
+
+// Dropped returns the number of events discarded under PolicyDropOldest
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Unsubscribe stops the delivery and closes `C`, it is safe to call it more
+// than once.
+func (s *Subscription) Unsubscribe() {
+	s.quitOnce.Do(func() { close(s.quit) })
+	s.b.remove(s)
+	s.closeOnce.Do(func() { close(s.ch) })
+}
+
+func (s *Subscription) publish(e Event) {
+	switch s.policy {
+	case PolicyDropOldest:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for {
+			select {
+			case s.ch <- e:
+				return
+			default:
+			}
+			select {
+			case <-s.ch:
+				atomic.AddUint64(&s.dropped, 1)
+			default:
+			}
+		}
+	default:
+		select {
+		case s.ch <- e:
+		case <-s.quit:
+		}
+	}
+}
+
+// pump moves events from `in` to `out` through an unbounded queue
+func (s *Subscription) pump(in <-chan Event, out chan<- Event) {
+	defer close(out)
+
+	var queue []Event
+	for in != nil || len(queue) > 0 {
+		var send chan<- Event
+		var head Event
+		if len(queue) > 0 {
+			send, head = out, queue[0]
+		}
+
+		select {
+		case e, more := <-in:
+			if !more {
+				in = nil
+				continue
+			}
+			queue = append(queue, e)
+		case send <- head:
+			queue = queue[1:]
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// broker fans out the events of a task to its subscriptions
+type broker struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func newBroker() *broker {
+	return &broker{subs: map[*Subscription]struct{}{}}
+}
+
+func (b *broker) subscribe(policy Policy, size int) *Subscription {
+	if size < 1 {
+		size = 1
+	}
+
+	s := &Subscription{
+		policy: policy,
+		b:      b,
+		quit:   make(chan interface{}),
+	}
+
+	if policy == PolicyUnbounded {
+		s.ch = make(chan Event, size)
+		out := make(chan Event, size)
+		s.C = out
+		go s.pump(s.ch, out)
+	} else {
+		s.ch = make(chan Event, size)
+		s.C = s.ch
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		s.closeOnce.Do(func() { close(s.ch) })
+		return s
+	}
+
+	b.subs[s] = struct{}{}
+	return s
+}
+
+func (b *broker) remove(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, s)
+}
+
+func (b *broker) publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for s := range b.subs {
+		s.publish(e)
+	}
+}
+
+// close closes all the subscriptions, no event could be published afterwards
+func (b *broker) close() {
+	b.mu.Lock()
+	b.closed = true
+	subs := b.subs
+	b.subs = map[*Subscription]struct{}{}
+	b.mu.Unlock()
+
+	for s := range subs {
+		s.closeOnce.Do(func() { close(s.ch) })
+	}
+}
+
+// Subscribe returns a new subscription to the events of the task, `size` is
+// the capacity of the subscription buffer. Subscribing to a completed task
+// returns a subscription whose channel is already closed.
+func (t *Task) Subscribe(policy Policy, size int) *Subscription {
+	return t.broker.subscribe(policy, size)
+}
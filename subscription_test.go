@@ -0,0 +1,66 @@
+package pico
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribe(t *testing.T) {
+	task, err := Convert(fakeFiles(t, 0.005, 30)[0], fakeOptions(t, WithJob(3))...)
+	assert.NoError(t, err, "conversion task initialization should not failed")
+
+	// subscriptions not read until the task is done must not block the
+	// conversion
+	cases := []struct {
+		policy  Policy
+		size    int
+		read    bool
+		pages   int
+		dropped bool
+	}{
+		{PolicyBlock, 1, true, 30, false},
+		{PolicyUnbounded, 1, false, 30, false},
+		{PolicyDropOldest, 2, false, -1, true},
+	}
+
+	subs := make([]*Subscription, len(cases))
+	pages := make([]int, len(cases))
+	var wg sync.WaitGroup
+	count := func(i int) {
+		defer wg.Done()
+		for e := range subs[i].C {
+			if e.Kind == EventPageDone {
+				pages[i]++
+			}
+		}
+	}
+
+	for i, c := range cases {
+		subs[i] = task.Subscribe(c.policy, c.size)
+		if c.read {
+			wg.Add(1)
+			go count(i)
+		}
+	}
+	task.Wait()
+	for i, c := range cases {
+		if !c.read {
+			wg.Add(1)
+			go count(i)
+		}
+	}
+	wg.Wait()
+
+	for i, c := range cases {
+		if c.pages >= 0 {
+			assert.Equal(t, c.pages, pages[i], "policy %d", c.policy)
+		}
+		assert.Equal(t, c.dropped, subs[i].Dropped() > 0, "policy %d", c.policy)
+	}
+
+	subs[len(subs)-1].Unsubscribe()
+	_, more := <-task.Subscribe(PolicyBlock, 1).C
+	assert.False(t, more, "subscription of a completed task should be closed")
+}
@@ -1,14 +1,42 @@
 package pico
 
 import (
+	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
+// _taskSeq generates task ids, which are used to tell tasks apart in logs
+var _taskSeq uint64
+
+// task kinds
+const (
+	KindSingle = "single"
+	KindBatch  = "batch"
+)
+
 type Task struct {
-	// FileProgress is measured by file counts
+	id uint64
+
+	// written is the bytes of the outputs, it is kept 64-bit aligned
+	written int64
+
+	// abortOnce ensures the task is aborted by a budget only once
+	abortOnce sync.Once
+
+	// kind is either KindSingle or KindBatch
+	kind string
+
+	// Progress is measured by pages for SingleTask, and by file counts for
+	// BatchTask
 	Progress
+	lifecycle
+
+	// Pages is the progress of the task measured by pages
+	Pages *PageProgress
 
 	// wg waits for all convertor completed
 	wg *sync.WaitGroup
@@ -23,6 +51,31 @@ type Task struct {
 	// the format will be ["currentPage" "lastPage" "filename" "workerId"]
 	Entries chan []string
 
+	// segments queues the entry streams of convertors in the order they should
+	// be delivered, it is nil unless `WithOrderedEntries` is given
+	segments chan chan []string
+
+	// delivered is closed when all the ordered entries have been delivered
+	delivered chan interface{}
+
+	// metrics feeds the metrics registry given by `WithMetrics`
+	metrics *taskMetrics
+
+	// traceCtx carries the task span, which is the parent of file spans
+	traceCtx context.Context
+	span     Span
+
+	// pages records converted pages of every file for `Report()`
+	records pageRecorder
+
+	// broker fans out the task events to subscribers
+	broker *broker
+
+	// provider is the provider given to `Start`, it is closed once the
+	// convertors are done, and its errors are reported by `Errors()`
+	provider       PdfProvider
+	providerErrors []*ConversionError
+
 	// done is the channel that, when it is closed, all the task is completed
 	done chan interface{}
 }
@@ -42,11 +95,74 @@ type BatchTask struct {
 
 func (t *Task) wait() {
 	t.wg.Wait()
+	t.closeProvider()
+	if !t.State().Terminal() {
+		t.setState(StateDraining)
+	}
+	if t.segments != nil {
+		close(t.segments)
+		<-t.delivered
+	}
 	t.params.cancel()
 	close(t.Entries)
+
+	t.timing.stop(time.Now())
+
+	e := TaskEvent{
+		Pages:    t.Pages.Finished(),
+		Duration: t.timing.elapsed(),
+		Errors:   t.errors(),
+	}
+
+	var err error
+	if len(e.Errors) > 0 {
+		err = e.Errors[0]
+	}
+	t.span.SetAttributes(Attribute{"pages_converted", e.Pages}, Attribute{"errors", len(e.Errors)})
+	endSpan(t.span, err)
+
+	t.params.logger.Log(LevelInfo, "task done", Field{"task", t.id}, Field{"kind", t.kind},
+		Field{"pages", e.Pages}, Field{"duration", e.Duration}, Field{"errors", len(e.Errors)})
+	if !t.State().Terminal() {
+		var budgetError *BudgetError
+		switch {
+		case errors.As(newMultiError(e.Errors...), &budgetError):
+			t.setState(StateFailed)
+		case t.Aborted():
+			t.setState(StateCancelled)
+		case len(e.Errors) > 0:
+			t.setState(StateFailed)
+		default:
+			t.setState(StateCompleted)
+		}
+	}
+	t.emitTaskDone(e)
 	close(t.done)
 }
 
+// setState moves the task to state `s`, an invalid transition is a bug and
+// is logged
+func (t *Task) setState(s State) {
+	if err := t.transition(s); err != nil {
+		t.params.logger.Log(LevelWarn, "state transition", Field{"task", t.id}, Field{"error", err})
+	}
+}
+
+// BytesWritten returns the bytes of the outputs written so far
+func (t *Task) BytesWritten() int64 {
+	return atomic.LoadInt64(&t.written)
+}
+
+// Kind reports whether the task is a SingleTask or a BatchTask
+func (t *Task) Kind() string {
+	return t.kind
+}
+
+// Id returns the unique id of the task in this process
+func (t *Task) Id() uint64 {
+	return t.id
+}
+
 func (t *Task) Completed() bool {
 	select {
 	case <-t.done:
@@ -56,7 +172,17 @@ func (t *Task) Completed() bool {
 	}
 }
 
+// Aborted reports whether the task or any convertor was stopped by
+// cancellation
 func (t *Task) Aborted() bool {
+	if t.State() == StateCancelled {
+		return true
+	}
+	for _, c := range t.Convertors {
+		if c.Aborted() {
+			return true
+		}
+	}
 	return false
 }
 
@@ -78,21 +204,83 @@ func (t *Task) WaitAndCollect() (entries [][]string) {
 	return entries
 }
 
-func (t *Task) Errors() (errs []*ConversionError) {
+func (t *Task) Errors() []*ConversionError {
 	<-t.done
+	return t.errors()
+}
+
+func (t *Task) errors() (errs []*ConversionError) {
 	for _, c := range t.Convertors {
 		errs = append(errs, c.Errors()...)
 	}
-	return
+	return append(errs, t.providerErrors...)
 }
 
+// closeProvider stops the provider, which may still be enumerating if the
+// task is cancelled, and collects its errors
+func (t *Task) closeProvider() {
+	if t.provider == nil {
+		return
+	}
+	t.provider.Close()
+
+	err := t.provider.Err()
+	if err == nil {
+		return
+	}
+	errs := []error{err}
+	if multi, ok := err.(*MultiError); ok {
+		errs = multi.Errors
+	}
+	for _, err := range errs {
+		converr := &ConversionError{page: -1, workerId: -1, err: err}
+		t.providerErrors = append(t.providerErrors, converr)
+		t.emitError(converr)
+	}
+}
+
+// Error returns a *MultiError of all the errors, or nil if there is none
 func (t *Task) Error() error {
 	if errs := t.Errors(); len(errs) > 0 {
-		return errs[0]
+		return newMultiError(errs...)
 	}
 	return nil
 }
 
+// deliverOrdered forwards the queued entry streams to `Entries` one after
+// another, so that entries of a stream are never interleaved with others.
+func (t *Task) deliverOrdered() {
+	defer close(t.delivered)
+	for segment := range t.segments {
+		for entry := range segment {
+			t.Entries <- entry
+		}
+	}
+}
+
+// enableOrdered prepares the segment queue if entries should be delivered
+// in order, `size` is the max number of segments that could be queued.
+func (t *Task) enableOrdered(size int32) {
+	if t.params.orderedBuffer <= 0 {
+		return
+	}
+
+	t.segments = make(chan chan []string, size)
+	t.delivered = make(chan interface{})
+	go t.deliverOrdered()
+}
+
+// Stats aggregates the timing statistics of all the convertors, the throughput
+// is the sum of the throughput of running convertors.
+func (t *Task) Stats() Stats {
+	timings := []*timing{&t.timing}
+	for _, c := range t.Convertors {
+		timings = append(timings, &c.timing)
+	}
+
+	return merge(timings, int64(t.Pages.Total()-t.Pages.Finished()))
+}
+
 func (t *Task) buildConvertor(index int32) *Convertor {
 	return &Convertor{
 		t:    t,
@@ -102,35 +290,147 @@ func (t *Task) buildConvertor(index int32) *Convertor {
 }
 
 func newSingleTask(p *Parameters) *SingleTask {
-	return &SingleTask{Task{
-		wg:     &sync.WaitGroup{},
-		done:   make(chan interface{}),
-		params: p,
+	t := &SingleTask{Task{
+		id:      atomic.AddUint64(&_taskSeq, 1),
+		kind:    KindSingle,
+		wg:      &sync.WaitGroup{},
+		done:    make(chan interface{}),
+		params:  p,
+		broker:  newBroker(),
+		metrics: newTaskMetrics(p.metrics, p, KindSingle),
 
 		Entries: make(chan []string, p.pageCount),
 	}}
+	t.Pages = &PageProgress{t: &t.Task}
+
+	return t
 }
 
 func newBatchTask(p *Parameters) *BatchTask {
-	return &BatchTask{Task{
-		wg:     &sync.WaitGroup{},
-		done:   make(chan interface{}),
-		params: p,
+	t := &BatchTask{Task{
+		id:      atomic.AddUint64(&_taskSeq, 1),
+		kind:    KindBatch,
+		wg:      &sync.WaitGroup{},
+		done:    make(chan interface{}),
+		params:  p,
+		broker:  newBroker(),
+		metrics: newTaskMetrics(p.metrics, p, KindBatch),
 
 		Entries: make(chan []string, 200),
 	}}
+	t.Pages = &PageProgress{t: &t.Task}
+
+	return t
+}
+
+// preflight collects all the files from provider and counts their pages, the
+// files are then served by a new provider. It stops early if the task is
+// cancelled, the convertors then stop at once.
+func (t *BatchTask) preflight(provider PdfProvider) PdfProvider {
+	p := t.params
+	p.pageCounts = map[string]int32{}
+	p.pageInfos = map[string]map[string]string{}
+
+	var source <-chan string
+	var itemCh <-chan Item
+	if ip, ok := provider.(ItemProvider); ok {
+		itemCh = ip.Items()
+	} else {
+		source = provider.Source()
+	}
+
+	items := []Item{}
+collect:
+	for {
+		var item Item
+		more := false
+
+		select {
+		case <-p.ctx.Done():
+			break collect
+		case item.File, more = <-source:
+		case item, more = <-itemCh:
+		}
+		if !more {
+			break
+		}
+		items = append(items, item)
+	}
+
+	total := int32(0)
+	for _, item := range items {
+		if p.ctx.Err() != nil {
+			break
+		}
+		pdf := item.File
+
+		// files failed here will fail again and be reported by the convertor
+		fp, err := p.override(item.Options)
+		if err != nil {
+			continue
+		}
+		if _, ok := p.pageCounts[pdf]; !ok {
+			pages, infos, err := fp.pdfInfo(pdf, WithContext(p.ctx))
+			if err != nil {
+				continue
+			}
+			p.pageCounts[pdf], p.pageInfos[pdf] = pages, infos
+		}
+
+		if first, last, err := fp.pageRangeForFile(p.pageCounts[pdf]); err == nil {
+			total += last - first + 1
+		}
+	}
+	t.Pages.setInit("", 1, total)
+
+	// nothing is provided if the task is cancelled, so that the convertors
+	// are stopped by the cancellation rather than the end of the files
+	if p.ctx.Err() != nil {
+		return FromChan(nil)
+	}
+
+	if _, ok := provider.(ItemProvider); ok {
+		return FromItems(items)
+	}
+
+	files := make([]string, len(items))
+	for i, item := range items {
+		files[i] = item.File
+	}
+	return FromSlice(files)
 }
 
 // Start initiates the conversion process
 func (t *SingleTask) Start(pdf string) error {
+	t.timing.start(time.Now())
+
+	p := t.params
+	t.traceCtx, t.span = p.tracer.Start(p.ctx, SpanTask,
+		append(p.spanAttributes(p.pageCount), Attribute{"kind", t.kind}, Attribute{"file", pdf})...)
+
+	t.setInit(pdf, p.firstPage, p.lastPage)
+	t.Pages.setInit(pdf, p.firstPage, p.lastPage)
+	t.setState(StateRunning)
+
+	// every convertor converts exactly one part of the file, and they are
+	// started in page order
+	t.enableOrdered(t.params.job)
+
 	for i := int32(0); i < t.params.job; i++ {
 		c := t.buildConvertor(i)
 
 		t.wg.Add(1)
 		t.Convertors = append(t.Convertors, c)
+		t.metrics.workers(1)
 
 		if err := c.start(pdf); err != nil {
+			// the started convertors are cancelled and the task is
+			// finished by `wait()` as usual
+			c.receiveError(err, -1)
+			c.onComplete()
+			t.setState(StateFailed)
 			t.params.cancel()
+			go t.wait()
 			return errors.Wrap(err, "failed to start convertor")
 		}
 	}
@@ -141,15 +441,51 @@ func (t *SingleTask) Start(pdf string) error {
 }
 
 func (t *BatchTask) Start(provider PdfProvider) error {
+	t.timing.start(time.Now())
+
+	p := t.params
+	t.traceCtx, t.span = p.tracer.Start(p.ctx, SpanTask,
+		append(p.spanAttributes(-1), Attribute{"kind", t.kind})...)
+	t.provider = provider
+
+	t.enableOrdered(t.params.job)
+
 	for i := int32(0); i < t.params.job; i++ {
 		c := t.buildConvertor(i)
 
 		t.wg.Add(1)
 		t.Convertors = append(t.Convertors, c)
-		go c.startAsWorker(provider)
+		t.metrics.workers(1)
 	}
 
-	go t.wait()
+	// the files are collected and counted by the task goroutine, so that a
+	// channel provider could be fed after `Start` returns
+	if t.params.preflight {
+		t.setState(StatePreflight)
+	}
+	go t.run(provider)
 
 	return nil
 }
+
+// run starts the convertors, after the preflight if it is enabled
+func (t *BatchTask) run(provider PdfProvider) {
+	if t.params.preflight {
+		provider = t.preflight(provider)
+	}
+	t.setState(StateRunning)
+
+	// set the total number as long as we could get the file count from provider
+	if cnt := provider.Count(); cnt > 0 {
+		t.setInit("", 1, int32(cnt))
+	}
+	if total := t.Pages.Total(); total > 0 {
+		t.span.SetAttributes(Attribute{"page_count", total})
+	}
+
+	for _, c := range t.Convertors {
+		go c.startAsWorker(provider)
+	}
+
+	t.wait()
+}
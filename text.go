@@ -0,0 +1,79 @@
+package pico
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// WithTextLayout keeps the physical layout of the text extracted by GetText()
+func WithTextLayout() CallOption {
+	return func(p *Parameters, command []string) []string {
+		p.textLayout = true
+		return command
+	}
+}
+
+// GetText extracts the text of the pdf with pdftotext, the page range is set
+// by `WithFirstPage` and `WithLastPage`. Passwords are resolved like GetInfo(),
+// by pdftotext itself.
+func GetText(pdf string, options ...CallOption) (string, error) {
+	p := defaultGetInfoCallArguments()
+	for _, option := range options {
+		option(p, nil)
+	}
+	p.setupLogger()
+
+	if _, err := os.Stat(pdf); err != nil {
+		return "", errors.WithStack(newIOError(err))
+	}
+
+	var text string
+	_, err := p.tryPasswords(pdf, func(pw Password) (err error) {
+		text, err = p.runPdftotext(pdf, pw)
+		return err
+	})
+	return text, err
+}
+
+func (p *Parameters) runPdftotext(pdf string, pw Password) (string, error) {
+	command := []string{getCommandPath("pdftotext", p.popplerPath)}
+	if p.firstPage > 0 {
+		command = append(command, "-f", strconv.Itoa(int(p.firstPage)))
+	}
+	if p.lastPage > 0 {
+		command = append(command, "-l", strconv.Itoa(int(p.lastPage)))
+	}
+	if p.textLayout {
+		command = append(command, "-layout")
+	}
+	command = append(command, pw.args()...)
+	command = append(command, pdf, "-")
+
+	ctx := p.ctx
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := buildCmd(ctx, p.popplerPath, command)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	p.logger.Log(LevelDebug, "spawn command", Field{"file", pdf}, Field{"command", command})
+
+	if err := cmd.Run(); err != nil {
+		p.logger.Log(LevelError, "pdftotext failed", Field{"file", pdf}, Field{"error", err},
+			Field{"output", strings.TrimSpace(stderr.String())})
+		if perr := classifyPopplerOutput(stderr.String(), !pw.empty(), err); perr != nil {
+			return "", errors.WithStack(perr)
+		}
+		return "", errors.WithStack(err)
+	}
+
+	return stdout.String(), nil
+}
@@ -0,0 +1,46 @@
+package pico
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetText(t *testing.T) {
+	poppler := fakePoppler(t)
+	calls := countPdfinfo(t, poppler)
+	dir := t.TempDir()
+
+	text, err := GetText(fakePDF(t, dir, "text.pdf", 1, 0, "Text: hello", "Text: world"),
+		WithPopplerPath(poppler), WithTextLayout())
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\nworld\n", text)
+
+	locked := fakePDF(t, dir, "locked.pdf", 1, 0, "Password: pw", "Text: secret")
+	var encryptedError *EncryptedError
+	_, err = GetText(locked, WithPopplerPath(poppler))
+	assert.ErrorAs(t, err, &encryptedError)
+
+	text, err = GetText(locked, WithPopplerPath(poppler), WithPasswordProvider(PasswordCandidates{"x", "pw"}))
+	assert.NoError(t, err)
+	assert.Equal(t, "secret\n", text)
+
+	// passwords are resolved by pdftotext itself
+	assert.Zero(t, calls())
+}
+
+func TestGetPopplerVersion(t *testing.T) {
+	poppler := fakePoppler(t)
+
+	version, err := GetPopplerVersion("pdftoppm", WithPopplerPath(poppler))
+	assert.NoError(t, err)
+	assert.Equal(t, "22.02.0", version)
+
+	// zero means no timeout
+	version, err = GetPopplerVersion("pdftoppm", WithPopplerPath(poppler), WithTimeout(0))
+	assert.NoError(t, err)
+	assert.Equal(t, "22.02.0", version)
+
+	_, err = GetPopplerVersion("pdfnothing", WithPopplerPath(poppler))
+	assert.Error(t, err)
+}
@@ -0,0 +1,182 @@
+package pico
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Attribute is a key-value pair attached to a span
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Span is a unit of work, e.g. a task, a file, a poppler subprocess or a page
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Tracer is a minimal tracing interface which could be easily adapted to
+// OpenTelemetry or any other tracing library. pico emits spans in the
+// hierarchy of task -> file -> subprocess -> page, the task span is started
+// with the context given by `WithContext` as its parent.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// span names
+const (
+	SpanTask       = "pico.task"
+	SpanFile       = "pico.file"
+	SpanSubprocess = "pico.subprocess"
+	SpanPage       = "pico.page"
+)
+
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttributes(...Attribute) {}
+func (nopSpan) RecordError(error)          {}
+func (nopSpan) End()                       {}
+
+// WithTracer sets the tracer used to emit spans
+func WithTracer(tracer Tracer) CallOption {
+	return func(p *Parameters, command []string) []string {
+		p.tracer = tracer
+		return command
+	}
+}
+
+// spanAttributes are the attributes common to task and file spans
+func (p *Parameters) spanAttributes(pages int32) []Attribute {
+	format, _, _ := parseFormat(p.fmt, p.grayscale)
+	return []Attribute{
+		{"backend", p.binary},
+		{"dpi", p.dpi},
+		{"format", format},
+		{"page_count", pages},
+	}
+}
+
+// endSpan ends the span if it is not nil, the error is recorded if any
+func endSpan(span Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// RecordedSpan is a span recorded by SpanRecorder
+type RecordedSpan struct {
+	ID       uint64
+	ParentID uint64
+	Name     string
+
+	Attributes map[string]interface{}
+	Errors     []error
+
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// Ended reports whether the span has been ended
+func (s RecordedSpan) Ended() bool {
+	return !s.EndTime.IsZero()
+}
+
+// SpanRecorder is an in-memory Tracer, which is useful in tests
+type SpanRecorder struct {
+	mu    sync.Mutex
+	seq   uint64
+	spans []*RecordedSpan
+}
+
+type recorderSpanKey struct{}
+
+func NewSpanRecorder() *SpanRecorder {
+	return &SpanRecorder{}
+}
+
+func (r *SpanRecorder) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seq++
+	s := &RecordedSpan{
+		ID:         r.seq,
+		Name:       name,
+		Attributes: map[string]interface{}{},
+		StartTime:  time.Now(),
+	}
+	if parent, ok := ctx.Value(recorderSpanKey{}).(uint64); ok {
+		s.ParentID = parent
+	}
+	for _, attr := range attrs {
+		s.Attributes[attr.Key] = attr.Value
+	}
+	r.spans = append(r.spans, s)
+
+	return context.WithValue(ctx, recorderSpanKey{}, s.ID), &recorderSpan{r: r, s: s}
+}
+
+// Spans returns a snapshot of all the recorded spans in the order they started
+func (r *SpanRecorder) Spans() []RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	spans := make([]RecordedSpan, len(r.spans))
+	for i, s := range r.spans {
+		spans[i] = *s
+		spans[i].Attributes = map[string]interface{}{}
+		for k, v := range s.Attributes {
+			spans[i].Attributes[k] = v
+		}
+		spans[i].Errors = append([]error{}, s.Errors...)
+	}
+	return spans
+}
+
+// Reset discards all the recorded spans
+func (r *SpanRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = nil
+}
+
+type recorderSpan struct {
+	r *SpanRecorder
+	s *RecordedSpan
+}
+
+func (s *recorderSpan) SetAttributes(attrs ...Attribute) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	for _, attr := range attrs {
+		s.s.Attributes[attr.Key] = attr.Value
+	}
+}
+
+func (s *recorderSpan) RecordError(err error) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	s.s.Errors = append(s.s.Errors, err)
+}
+
+func (s *recorderSpan) End() {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	if s.s.EndTime.IsZero() {
+		s.s.EndTime = time.Now()
+	}
+}
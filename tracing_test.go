@@ -0,0 +1,69 @@
+package pico
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracing(t *testing.T) {
+	cases := []struct {
+		kind    string
+		pages   []int
+		options []CallOption
+	}{
+		{"SingleTask", []int{6}, nil},
+		{"BatchTask", []int{3, 3}, []CallOption{WithPreflight()}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.kind, func(t *testing.T) {
+			recorder := NewSpanRecorder()
+			parentCtx, parent := recorder.Start(context.Background(), "request")
+
+			task, err := converts[c.kind](fakeFiles(t, 0, c.pages...), fakeOptions(t, append(c.options,
+				WithJob(2),
+				WithDpi(150),
+				WithContext(parentCtx),
+				WithTracer(recorder),
+			)...)...)
+			assert.NoError(t, err, "conversion task initialization should not failed")
+			task.Wait()
+			parent.End()
+
+			spans := map[uint64]RecordedSpan{}
+			count := map[string]int{}
+			for _, span := range recorder.Spans() {
+				spans[span.ID] = span
+				count[span.Name]++
+				assert.True(t, span.Ended(), "span %s should be ended", span.Name)
+			}
+			assert.Equal(t, map[string]int{
+				"request":      1,
+				SpanTask:       1,
+				SpanFile:       2,
+				SpanSubprocess: 2,
+				SpanPage:       6,
+			}, count)
+
+			parentName := func(span RecordedSpan) string { return spans[span.ParentID].Name }
+			for _, span := range spans {
+				switch span.Name {
+				case SpanTask:
+					assert.Equal(t, "request", parentName(span))
+					assert.Equal(t, 150, span.Attributes["dpi"])
+					assert.Equal(t, "pdftoppm", span.Attributes["backend"])
+					assert.EqualValues(t, 6, span.Attributes["page_count"])
+				case SpanFile:
+					assert.Equal(t, SpanTask, parentName(span))
+					assert.EqualValues(t, 3, span.Attributes["page_count"])
+				case SpanSubprocess:
+					assert.Equal(t, SpanFile, parentName(span))
+				case SpanPage:
+					assert.Equal(t, SpanSubprocess, parentName(span))
+				}
+			}
+		})
+	}
+}
@@ -37,6 +37,34 @@ func getPopplerVersion(ctx context.Context, binary, popplerPath string) ([]int,
 	return []int{major, minor}, nil
 }
 
+// GetPopplerVersion returns the version of a poppler utility like pdftoppm,
+// the utility is looked up in the path given by `WithPopplerPath`
+func GetPopplerVersion(binary string, options ...CallOption) (string, error) {
+	p := defaultGetInfoCallArguments()
+	for _, option := range options {
+		option(p, nil)
+	}
+
+	ctx := p.ctx
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	command := []string{getCommandPath(binary, p.popplerPath), "-v"}
+	buf, err := buildCmd(ctx, p.popplerPath, command).CombinedOutput()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to run %s", command[0])
+	}
+
+	version := _versionRE.FindString(string(buf))
+	if version == "" {
+		return "", errors.WithStack(NewGetBinaryVersionError(binary))
+	}
+	return version, nil
+}
+
 func getCommandPath(binary, popplerPath string) string {
 	// it seems redundant to add `.exe` extension to the binary name,
 	// but the Python version pdf2image does so.
@@ -0,0 +1,295 @@
+package pico
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SymlinkPolicy decides how the walking provider treats symbolic links
+type SymlinkPolicy int
+
+const (
+	// SymlinkSkip ignores symbolic links
+	SymlinkSkip SymlinkPolicy = iota
+
+	// SymlinkFiles follows symbolic links to files but not to directories
+	SymlinkFiles
+
+	// SymlinkFollow follows symbolic links to files and directories, a
+	// directory is walked only once even if it is linked more than once
+	SymlinkFollow
+)
+
+// _sniffSize is how many leading bytes are searched for the pdf header, some
+// producers write garbage before it and readers tolerate up to 1024 bytes
+const _sniffSize = 1024
+
+var _pdfHeader = []byte("%PDF-")
+
+// WalkOption configures the walking provider
+type WalkOption func(w *walker)
+
+// WalkRecursive walks into subdirectories
+func WalkRecursive() WalkOption {
+	return func(w *walker) {
+		w.recursive = true
+	}
+}
+
+// WalkInclude accepts only the files matching any of the patterns. Patterns
+// follow `filepath.Match` and are matched against the file name, or against
+// the path relative to the walked directory if they contain a separator.
+func WalkInclude(patterns ...string) WalkOption {
+	return func(w *walker) {
+		w.include = append(w.include, patterns...)
+	}
+}
+
+// WalkExclude skips the files and directories matching any of the patterns,
+// patterns are matched like those of WalkInclude
+func WalkExclude(patterns ...string) WalkOption {
+	return func(w *walker) {
+		w.exclude = append(w.exclude, patterns...)
+	}
+}
+
+// WalkSize accepts only the files whose size is in [min, max], a non-positive
+// bound is not checked
+func WalkSize(min, max int64) WalkOption {
+	return func(w *walker) {
+		w.minSize, w.maxSize = min, max
+	}
+}
+
+// WalkModifiedSince accepts only the files modified at or after `t`
+func WalkModifiedSince(t time.Time) WalkOption {
+	return func(w *walker) {
+		w.modifiedSince = t
+	}
+}
+
+// WalkSymlinks sets the symbolic link policy, the default is SymlinkSkip
+func WalkSymlinks(policy SymlinkPolicy) WalkOption {
+	return func(w *walker) {
+		w.symlinks = policy
+	}
+}
+
+type walker struct {
+	recursive     bool
+	include       []string
+	exclude       []string
+	minSize       int64
+	maxSize       int64
+	modifiedSince time.Time
+	symlinks      SymlinkPolicy
+
+	// visited are the real paths of walked directories
+	visited map[string]bool
+}
+
+// WalkProvider walks files and directories for pdf files. Files are detected
+// by their content rather than extension, files given explicitly are always
+// provided while files matched by glob patterns are filtered.
+type WalkProvider struct {
+	*ChanProvider
+}
+
+// FromWalk returns a provider that walks the paths asynchronously, paths
+// could be files, directories or glob patterns. Unreadable paths are
+// reported by `Err()` and skipped.
+func FromWalk(paths []string, options ...WalkOption) *WalkProvider {
+	w := &walker{visited: map[string]bool{}}
+	for _, option := range options {
+		option(w)
+	}
+
+	p := &WalkProvider{newAsyncProvider()}
+	go func() {
+		defer close(p.source)
+		for _, path := range paths {
+			if !p.walkRoot(w, path) {
+				return
+			}
+		}
+	}()
+
+	return p
+}
+
+// walkRoot walks a path given by the caller, false is returned if the
+// provider is closed
+func (p *WalkProvider) walkRoot(w *walker, path string) bool {
+	info, err := os.Stat(path)
+	if err != nil && hasMeta(path) {
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			p.error(errors.Wrapf(err, "invalid pattern %q", path))
+			return true
+		}
+		for _, match := range matches {
+			if !p.walkMatch(w, match) {
+				return false
+			}
+		}
+		return true
+	}
+	if err != nil {
+		p.error(errors.WithStack(err))
+		return true
+	}
+
+	if !info.IsDir() {
+		return p.send(path)
+	}
+	return p.walkDir(w, path, "")
+}
+
+// walkMatch walks a path matched by a glob pattern, unlike the paths given
+// explicitly, matched files are filtered like the files in a directory
+func (p *WalkProvider) walkMatch(w *walker, path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		p.error(errors.WithStack(err))
+		return true
+	}
+
+	name := filepath.Base(path)
+	if info.IsDir() {
+		if w.excluded(name) {
+			return true
+		}
+		return p.walkDir(w, path, "")
+	}
+	return p.walkEntry(w, path, name, info)
+}
+
+// walkDir walks `dir`, `rel` is the path of `dir` relative to the root
+func (p *WalkProvider) walkDir(w *walker, dir, rel string) bool {
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		if w.visited[real] {
+			return true
+		}
+		w.visited[real] = true
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		p.error(errors.WithStack(err))
+		return true
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !p.walkEntry(w, path, filepath.Join(rel, entry.Name()), entry) {
+			return false
+		}
+	}
+	return true
+}
+
+// walkEntry filters and provides a file found by walking, directories are
+// walked if the walk is recursive. `entry` is not followed if it is a
+// symbolic link.
+func (p *WalkProvider) walkEntry(w *walker, path, rel string, entry os.FileInfo) bool {
+	if w.excluded(rel) {
+		return true
+	}
+
+	if entry.Mode()&os.ModeSymlink != 0 {
+		if w.symlinks == SymlinkSkip {
+			return true
+		}
+		var err error
+		if entry, err = os.Stat(path); err != nil {
+			p.error(errors.WithStack(err))
+			return true
+		}
+		if entry.IsDir() && w.symlinks != SymlinkFollow {
+			return true
+		}
+	}
+
+	if entry.IsDir() {
+		return !w.recursive || p.walkDir(w, path, rel)
+	}
+
+	if !entry.Mode().IsRegular() || !w.accepted(rel, entry) {
+		return true
+	}
+
+	ok, err := sniffPDFHeader(path)
+	if err != nil {
+		p.error(err)
+		return true
+	}
+	return !ok || p.send(path)
+}
+
+func (w *walker) excluded(rel string) bool {
+	return matchAny(w.exclude, rel)
+}
+
+func (w *walker) accepted(rel string, info os.FileInfo) bool {
+	if len(w.include) > 0 && !matchAny(w.include, rel) {
+		return false
+	}
+	if w.minSize > 0 && info.Size() < w.minSize {
+		return false
+	}
+	if w.maxSize > 0 && info.Size() > w.maxSize {
+		return false
+	}
+	if !w.modifiedSince.IsZero() && info.ModTime().Before(w.modifiedSince) {
+		return false
+	}
+	return true
+}
+
+// matchAny matches the patterns against the file name, or against the
+// relative path if the pattern contains a separator
+func matchAny(patterns []string, rel string) bool {
+	name := filepath.Base(rel)
+	for _, pattern := range patterns {
+		target := name
+		if strings.ContainsRune(filepath.ToSlash(pattern), '/') {
+			target = filepath.ToSlash(rel)
+			pattern = filepath.ToSlash(pattern)
+		}
+		if ok, _ := filepath.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffPDFHeader reports whether the file starts with the pdf header
+func sniffPDFHeader(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, _sniffSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, errors.WithStack(err)
+	}
+	return bytes.Contains(buf[:n], _pdfHeader), nil
+}
+
+func hasMeta(path string) bool {
+	magicChars := `*?[`
+	if os.PathSeparator != '\\' {
+		magicChars = `*?[\`
+	}
+	return strings.ContainsAny(path, magicChars)
+}
@@ -0,0 +1,111 @@
+package pico
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, path, content string) string {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	return path
+}
+
+func collect(p PdfProvider) []string {
+	files := []string{}
+	for file := range p.Source() {
+		files = append(files, file)
+	}
+	return files
+}
+
+func TestWalkProvider(t *testing.T) {
+	dir := t.TempDir()
+
+	a := writeFile(t, filepath.Join(dir, "a.pdf"), "%PDF-1.7\n")
+	b := writeFile(t, filepath.Join(dir, "b.bin"), "garbage\n%PDF-1.4\n")
+	writeFile(t, filepath.Join(dir, "notes.pdf"), "not a pdf")
+	c := writeFile(t, filepath.Join(dir, "sub", "c.pdf"), "%PDF-1.7\n"+string(make([]byte, 100)))
+	d := writeFile(t, filepath.Join(dir, "sub", "deep", "d.pdf"), "%PDF-1.7\n")
+	writeFile(t, filepath.Join(dir, "skip", "e.pdf"), "%PDF-1.7\n")
+
+	assert.ElementsMatch(t, []string{a, b}, collect(FromWalk([]string{dir})))
+
+	p := FromWalk([]string{dir}, WalkRecursive(), WalkExclude("skip"))
+	assert.ElementsMatch(t, []string{a, b, c, d}, collect(p))
+	assert.Empty(t, p.Errors())
+
+	p = FromWalk([]string{dir}, WalkRecursive(), WalkInclude("*.pdf"), WalkExclude("sub/deep"))
+	assert.ElementsMatch(t, []string{a, c, filepath.Join(dir, "skip", "e.pdf")}, collect(p))
+
+	p = FromWalk([]string{dir}, WalkRecursive(), WalkSize(50, 0))
+	assert.ElementsMatch(t, []string{c}, collect(p))
+
+	old := time.Now().Add(-time.Hour)
+	for _, file := range []string{a, b, c} {
+		assert.NoError(t, os.Chtimes(file, old, old))
+	}
+	p = FromWalk([]string{dir}, WalkRecursive(), WalkModifiedSince(time.Now().Add(-time.Minute)))
+	assert.ElementsMatch(t, []string{d, filepath.Join(dir, "skip", "e.pdf")}, collect(p))
+
+	// glob matches are filtered like the files in a directory, matched
+	// directories are walked like the directories given explicitly
+	e := filepath.Join(dir, "skip", "e.pdf")
+	p = FromWalk([]string{filepath.Join(dir, "*")})
+	assert.ElementsMatch(t, []string{a, b, c, e}, collect(p))
+	p = FromWalk([]string{filepath.Join(dir, "*")}, WalkInclude("*.pdf"), WalkExclude("a.pdf", "skip"))
+	assert.ElementsMatch(t, []string{c}, collect(p))
+
+	// explicit files are provided without sniffing
+	missing := filepath.Join(dir, "missing")
+	p = FromWalk([]string{filepath.Join(dir, "notes.pdf"), filepath.Join(dir, "sub", "*.pdf"), missing})
+	assert.ElementsMatch(t, []string{c, filepath.Join(dir, "notes.pdf")}, collect(p))
+	if assert.Len(t, p.Errors(), 1) {
+		assert.True(t, errors.Is(p.Errors()[0], os.ErrNotExist))
+		assert.Contains(t, p.Errors()[0].Error(), missing)
+	}
+}
+
+func TestWalkSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need privileges on windows")
+	}
+
+	dir := t.TempDir()
+	target := t.TempDir()
+
+	a := writeFile(t, filepath.Join(dir, "a.pdf"), "%PDF-1.7\n")
+	b := writeFile(t, filepath.Join(target, "b.pdf"), "%PDF-1.7\n")
+	assert.NoError(t, os.Symlink(b, filepath.Join(dir, "link.pdf")))
+	assert.NoError(t, os.Symlink(target, filepath.Join(dir, "linked")))
+	// a loop is walked only once
+	assert.NoError(t, os.Symlink(dir, filepath.Join(target, "loop")))
+
+	assert.ElementsMatch(t, []string{a}, collect(FromWalk([]string{dir}, WalkRecursive())))
+	assert.ElementsMatch(t, []string{a, filepath.Join(dir, "link.pdf")},
+		collect(FromWalk([]string{dir}, WalkRecursive(), WalkSymlinks(SymlinkFiles))))
+	assert.ElementsMatch(t, []string{a, filepath.Join(dir, "link.pdf"), filepath.Join(dir, "linked", "b.pdf")},
+		collect(FromWalk([]string{dir}, WalkRecursive(), WalkSymlinks(SymlinkFollow))))
+}
+
+func TestMultiSourceMissingPath(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, filepath.Join(dir, "a.pdf"), "%PDF-1.7\n")
+	missing := filepath.Join(dir, "missing.pdf")
+
+	assert.NotPanics(t, func() {
+		assert.ElementsMatch(t, []string{a, missing}, collect(FromMultiSource([]string{dir, missing})))
+		assert.ElementsMatch(t, []string{a}, collect(FromMultiSourceAsync([]string{filepath.Join(dir, "*.pdf")})))
+	})
+}